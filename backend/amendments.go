@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// amendSubmission checks corrected answers against the questions the
+// submission was made with, rescores them and stores them as its new answers
+func amendSubmission(c *gin.Context, submission *Submission, answers []Answer, amendedBy, reason string) {
+	var questions []Question
+	var scoring []ScoringRule
+	var err error
+	if submission.QuestionnaireID != "" && submission.Version > 0 {
+		var version *QuestionnaireVersion
+		if version, err = db.GetQuestionnaireVersion(submission.QuestionnaireID, submission.Version); err == nil {
+			questions, scoring = version.Questions, version.Scoring
+		}
+	} else {
+		questions, err = db.GetQuestions()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to validate questions"})
+		return
+	}
+
+	amended := &Submission{}
+	if _, errs := evaluateSubmission(amended, answers, questions, scoring); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, GenericResponse{
+			Success: false,
+			Data: ValidationErrorsResponse{
+				Message: "Invalid answers",
+				Errors:  errs,
+			},
+		})
+		return
+	}
+
+	submission, err = db.AmendSubmission(submission.ID, amended.Answers, amended.Scores, amendedBy, reason)
+	if err != nil {
+		if errors.Is(err, errSubmissionNotFound) {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Submission not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to amend submission"})
+		}
+		return
+	}
+	if isAdmin, _ := c.Get("isAdmin"); isAdmin != true {
+		*submission = ownSubmissions([]Submission{*submission})[0]
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: submission})
+}
+
+// ownSubmissions prepares submissions for the user who made them. Revisions
+// leave out who amended them and why, and scores are left out unless their
+// questionnaire shows them.
+func ownSubmissions(submissions []Submission) []Submission {
+	shown := make(map[string]bool)
+	for i := range submissions {
+		s := &submissions[i]
+		show := scoresShown(s, shown)
+		if !show {
+			s.Scores = nil
+		}
+		if len(s.Revisions) == 0 {
+			continue
+		}
+		revisions := make([]SubmissionRevision, len(s.Revisions))
+		for n, r := range s.Revisions {
+			r.AmendedBy, r.Reason = "", ""
+			if !show {
+				r.Scores = nil
+			}
+			revisions[n] = r
+		}
+		s.Revisions = revisions
+	}
+	return submissions
+}
+
+// handleAmendOwnSubmission lets users correct their own submission within
+// -amend-window of making it. Anonymous submissions have no owner and cannot
+// be amended this way.
+func handleAmendOwnSubmission(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req AmendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	submission, err := db.GetSubmission(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Submission not found"})
+		return
+	}
+
+	if submission.UserID == "" || submission.UserID != userID.(string) {
+		c.JSON(http.StatusForbidden, GenericResponse{Success: false, Data: "Access denied"})
+		return
+	}
+	if time.Since(submission.CreatedAt) > *amendWindow {
+		c.JSON(http.StatusForbidden, GenericResponse{Success: false, Data: "The submission can no longer be amended"})
+		return
+	}
+
+	amendSubmission(c, submission, req.Answers, userID.(string), strings.TrimSpace(req.Reason))
+}
+
+// handleAmendSubmission lets admins correct any submission at any time. The
+// reason is kept with the revision.
+func handleAmendSubmission(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req AmendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "A reason is required to amend a submission"})
+		return
+	}
+
+	submission, err := db.GetSubmission(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Submission not found"})
+		return
+	}
+
+	amendSubmission(c, submission, req.Answers, userID.(string), reason)
+}
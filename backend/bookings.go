@@ -0,0 +1,219 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bookingRange reads the ?from= and ?to= filters of a booking list
+func bookingRange(c *gin.Context) (from, to time.Time, err error) {
+	if v := c.Query("from"); v != "" {
+		if from, err = parseDateFilter(v, false); err != nil {
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = parseDateFilter(v, true); err != nil {
+			return
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		err = errors.New("to must be after from")
+	}
+	return
+}
+
+// bookingOverlaps reports whether a booking overlaps a range whose zero
+// bounds are open
+func bookingOverlaps(b Booking, from, to time.Time) bool {
+	return (from.IsZero() || b.End.After(from)) && (to.IsZero() || b.Start.Before(to))
+}
+
+func handleCreateBooking(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	var req BookingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	if req.Seats == 0 {
+		req.Seats = 1
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	var err error
+	switch {
+	case req.Seats < 0:
+		err = errors.New("seats must be at least 1")
+	case !req.End.After(req.Start):
+		err = errors.New("end must be after start")
+	case req.Start.Before(time.Now()):
+		err = errors.New("bookings cannot start in the past")
+	case req.End.Sub(req.Start) > *maxBooking:
+		err = errors.New("bookings can be at most " + maxBooking.String() + " long")
+	case utf8.RuneCountInString(req.Note) > 500:
+		err = errors.New("note must be at most 500 characters")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	booking := &Booking{
+		RoomID: req.RoomID,
+		UserID: userID.(string),
+		Start:  req.Start.UTC(),
+		End:    req.End.UTC(),
+		Seats:  req.Seats,
+		Note:   req.Note,
+	}
+	if err := db.CreateBooking(booking); err != nil {
+		switch {
+		case errors.Is(err, errRoomFull), errors.Is(err, errBookingOverlap):
+			c.JSON(http.StatusConflict, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to create booking"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: booking})
+}
+
+// handleGetMyBookings lists the user's bookings, cancelled ones included,
+// optionally limited to those overlapping ?from= and ?to=
+func handleGetMyBookings(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	from, to, err := bookingRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	bookings, err := db.GetBookings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch bookings"})
+		return
+	}
+
+	mine := []Booking{}
+	for _, b := range bookings {
+		if b.UserID == userID.(string) && bookingOverlaps(b, from, to) {
+			mine = append(mine, b)
+		}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: BookingsResponse{
+			Bookings: mine,
+		},
+	})
+}
+
+func cancelBooking(c *gin.Context, ownerID string) {
+	userID, _ := c.Get("userID")
+
+	booking, err := db.CancelBooking(c.Param("id"), ownerID, userID.(string))
+	if err != nil {
+		switch {
+		case errors.Is(err, errBookingNotFound):
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Booking not found"})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to cancel booking"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: booking})
+}
+
+// handleCancelOwnBooking cancels one of the user's own bookings
+func handleCancelOwnBooking(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	cancelBooking(c, userID.(string))
+}
+
+// handleCancelBooking lets admins cancel any booking
+func handleCancelBooking(c *gin.Context) {
+	cancelBooking(c, "")
+}
+
+// handleGetBookingCalendar lists the bookings of each room between ?from=
+// and ?to=, by default the coming 7 days. Active rooms are always listed;
+// inactive ones only with bookings in the range. ?room_id= limits the
+// calendar to one room and ?include_cancelled=true adds cancelled bookings.
+func handleGetBookingCalendar(c *gin.Context) {
+	from, to, err := bookingRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+	switch {
+	case from.IsZero() && to.IsZero():
+		from = time.Now().UTC().Truncate(24 * time.Hour)
+	case from.IsZero():
+		from = to.AddDate(0, 0, -7)
+	}
+	if to.IsZero() {
+		to = from.AddDate(0, 0, 7)
+	}
+
+	rooms, err := db.GetRooms()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch rooms"})
+		return
+	}
+	bookings, err := db.GetBookings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch bookings"})
+		return
+	}
+
+	roomID := c.Query("room_id")
+	includeCancelled := c.Query("include_cancelled") == "true"
+	byRoom := make(map[string][]Booking)
+	for _, b := range bookings {
+		if b.CancelledAt != nil && !includeCancelled {
+			continue
+		}
+		if bookingOverlaps(b, from, to) {
+			byRoom[b.RoomID] = append(byRoom[b.RoomID], b)
+		}
+	}
+
+	calendar := []RoomCalendar{}
+	for _, r := range rooms {
+		if roomID != "" && r.ID != roomID {
+			continue
+		}
+		if !r.Active && len(byRoom[r.ID]) == 0 {
+			continue
+		}
+		roomBookings := byRoom[r.ID]
+		if roomBookings == nil {
+			roomBookings = []Booking{}
+		}
+		calendar = append(calendar, RoomCalendar{Room: r, Bookings: roomBookings})
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: CalendarResponse{
+			From:  from,
+			To:    to,
+			Rooms: calendar,
+		},
+	})
+}
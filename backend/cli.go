@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const commandUsage = `usage:
+  tiramisu [flags] export <questionnaire id or key> [json|yaml]
+  tiramisu [flags] import <file, or - for stdin>`
+
+// Command runs a command line subcommand against the database file instead
+// of starting the server. The server must not be running, as it holds a lock
+// on the database.
+func Command(args []string) error {
+	var err error
+	db, err = newDB(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "export":
+		return exportCommand(args[1:], os.Stdout)
+	case "import":
+		return importCommand(args[1:], os.Stdout)
+	}
+	return errors.New(commandUsage)
+}
+
+func exportCommand(args []string, out io.Writer) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New(commandUsage)
+	}
+	format := "yaml"
+	if len(args) == 2 {
+		format = args[1]
+	}
+	if format != "json" && format != "yaml" {
+		return errors.New("format must be json or yaml")
+	}
+
+	questionnaire, err := findQuestionnaire(args[0])
+	if err != nil {
+		return err
+	}
+	questions, err := db.GetQuestionnaireQuestions(questionnaire)
+	if err != nil {
+		return err
+	}
+
+	buf, err := marshalDocument(exportQuestionnaire(questionnaire, questions), format)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(buf)
+	return err
+}
+
+func importCommand(args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(commandUsage)
+	}
+
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	doc, err := parseDocument(data)
+	if err != nil {
+		return fmt.Errorf("invalid document: %w", err)
+	}
+	if err := validateDocument(doc); err != nil {
+		return err
+	}
+
+	result, err := db.ImportQuestionnaire(doc, false)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "questionnaire %s (%s): %s\n", doc.Key, result.QuestionnaireID, result.Questionnaire)
+	for _, group := range []struct {
+		name string
+		keys []string
+	}{{"created", result.Created}, {"updated", result.Updated}, {"unchanged", result.Unchanged}} {
+		if len(group.keys) > 0 {
+			fmt.Fprintf(out, "%s: %s\n", group.name, strings.Join(group.keys, ", "))
+		}
+	}
+	return nil
+}
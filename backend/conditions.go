@@ -0,0 +1,247 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var conditionOperators = []string{"eq", "ne", "gt", "gte", "lt", "lte", "contains", "answered"}
+
+// validateCondition checks the structure of a display condition and that every
+// comparison refers to another known question with a value it can take
+func validateCondition(cond *Condition, questions map[string]Question, selfID string) error {
+	parts := 0
+	if len(cond.All) > 0 {
+		parts++
+	}
+	if len(cond.Any) > 0 {
+		parts++
+	}
+	if cond.QuestionID != "" || cond.Operator != "" {
+		parts++
+	}
+	if parts != 1 {
+		return errors.New("a condition must have exactly one of all, any or a question_id comparison")
+	}
+
+	for i := range cond.All {
+		if err := validateCondition(&cond.All[i], questions, selfID); err != nil {
+			return err
+		}
+	}
+	for i := range cond.Any {
+		if err := validateCondition(&cond.Any[i], questions, selfID); err != nil {
+			return err
+		}
+	}
+	if cond.QuestionID == "" && cond.Operator == "" {
+		return nil
+	}
+
+	if cond.QuestionID == selfID {
+		return errors.New("a question cannot depend on itself")
+	}
+	ref, exists := questions[cond.QuestionID]
+	if !exists {
+		return errors.New("condition refers to unknown question: " + cond.QuestionID)
+	}
+
+	validOperator := false
+	for _, op := range conditionOperators {
+		if cond.Operator == op {
+			validOperator = true
+			break
+		}
+	}
+	if !validOperator {
+		return errors.New("invalid condition operator: " + cond.Operator)
+	}
+
+	if _, err := conditionValue(ref, cond.Operator, cond.Value); err != nil {
+		return errors.New("condition on " + cond.QuestionID + ": " + err.Error())
+	}
+	return nil
+}
+
+// conditionReaches reports whether a condition depends on a question,
+// directly or through the conditions of the questions it refers to
+func conditionReaches(questions map[string]Question, cond *Condition, target string, seen map[string]bool) bool {
+	for _, id := range conditionQuestionIDs(cond) {
+		if id == target {
+			return true
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if conditionReaches(questions, questions[id].Condition, target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionQuestionIDs returns every question a condition refers to
+func conditionQuestionIDs(cond *Condition) []string {
+	if cond == nil {
+		return nil
+	}
+	var ids []string
+	if cond.QuestionID != "" {
+		ids = append(ids, cond.QuestionID)
+	}
+	for i := range cond.All {
+		ids = append(ids, conditionQuestionIDs(&cond.All[i])...)
+	}
+	for i := range cond.Any {
+		ids = append(ids, conditionQuestionIDs(&cond.Any[i])...)
+	}
+	return ids
+}
+
+// checkConditions verifies that the conditions in an ordered list of
+// questions are valid and only refer to questions that come earlier
+func checkConditions(questions []Question) error {
+	earlier := make(map[string]Question)
+	for _, q := range questions {
+		if q.Condition == nil {
+			earlier[q.ID] = q
+			continue
+		}
+		for _, id := range conditionQuestionIDs(q.Condition) {
+			if _, ok := earlier[id]; !ok {
+				return errors.New("condition of question " + q.ID + " must refer to an earlier question: " + id)
+			}
+		}
+		if err := validateCondition(q.Condition, earlier, q.ID); err != nil {
+			return errors.New("condition of question " + q.ID + " is invalid: " + err.Error())
+		}
+		earlier[q.ID] = q
+	}
+	return nil
+}
+
+// conditionValue decodes the value a comparison is made against
+func conditionValue(ref Question, op string, raw json.RawMessage) (interface{}, error) {
+	switch op {
+	case "answered":
+		return nil, nil
+	case "contains":
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil || v == "" {
+			return nil, errors.New("contains requires a string value")
+		}
+		switch ref.Type {
+		case "multi_choice":
+			if !hasOption(ref, v) {
+				return nil, errors.New("value is not one of the options: " + v)
+			}
+			return v, nil
+		case "text":
+			return v, nil
+		}
+		return nil, errors.New("contains only applies to multi_choice and text questions")
+	case "gt", "gte", "lt", "lte":
+		if ref.Type != "scale" && ref.Type != "slider" {
+			return nil, errors.New(op + " only applies to scale and slider questions")
+		}
+		var v int
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, errors.New(op + " requires a whole number value")
+		}
+		return v, nil
+	}
+
+	v, err := parseAnswerValue(ref, Answer{Value: raw})
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, errors.New(op + " requires a value")
+	}
+	return v, nil
+}
+
+// evaluateCondition reports whether a condition holds for the answers given
+// so far. Comparisons on unanswered questions are false.
+func evaluateCondition(cond *Condition, questions map[string]Question, values map[string]interface{}) bool {
+	if len(cond.All) > 0 {
+		for i := range cond.All {
+			if !evaluateCondition(&cond.All[i], questions, values) {
+				return false
+			}
+		}
+		return true
+	}
+	if len(cond.Any) > 0 {
+		for i := range cond.Any {
+			if evaluateCondition(&cond.Any[i], questions, values) {
+				return true
+			}
+		}
+		return false
+	}
+
+	answer, answered := values[cond.QuestionID]
+	if cond.Operator == "answered" || !answered {
+		return answered
+	}
+
+	expected, err := conditionValue(questions[cond.QuestionID], cond.Operator, cond.Value)
+	if err != nil {
+		return false
+	}
+
+	switch cond.Operator {
+	case "eq":
+		return answersEqual(answer, expected)
+	case "ne":
+		return !answersEqual(answer, expected)
+	case "contains":
+		switch a := answer.(type) {
+		case []string:
+			for _, v := range a {
+				if v == expected {
+					return true
+				}
+			}
+		case string:
+			return strings.Contains(strings.ToLower(a), strings.ToLower(expected.(string)))
+		}
+		return false
+	}
+
+	a, ok := answer.(int)
+	if !ok {
+		return false
+	}
+	e := expected.(int)
+	switch cond.Operator {
+	case "gt":
+		return a > e
+	case "gte":
+		return a >= e
+	case "lt":
+		return a < e
+	case "lte":
+		return a <= e
+	}
+	return false
+}
+
+// answersEqual compares two answer values, treating multi_choice answers as sets
+func answersEqual(a, b interface{}) bool {
+	as, aok := a.([]string)
+	bs, bok := b.([]string)
+	if aok && bok {
+		as = append([]string(nil), as...)
+		bs = append([]string(nil), bs...)
+		sort.Strings(as)
+		sort.Strings(bs)
+		return reflect.DeepEqual(as, bs)
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -1,8 +1,16 @@
 package backend
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -13,8 +21,71 @@ var (
 	usersBucket       = []byte("users")
 	questionsBucket   = []byte("questions")
 	submissionsBucket = []byte("submissions")
+	studiesBucket     = []byte("studies")
+	secretsBucket     = []byte("secrets")
+
+	questionnairesBucket        = []byte("questionnaires")
+	questionnaireVersionsBucket = []byte("questionnaire_versions")
+	draftsBucket                = []byte("drafts")
+	schedulesBucket             = []byte("schedules")
+	participationBucket         = []byte("participation")
+	sessionsBucket              = []byte("sessions")
+	idempotencyBucket           = []byte("idempotency")
+	promptsBucket               = []byte("prompts")
+	roomsBucket                 = []byte("rooms")
+	bookingsBucket              = []byte("bookings")
+)
+
+var (
+	errQuestionnaireNotFound = errors.New("questionnaire not found")
+	errVersionNotFound       = errors.New("questionnaire version not found")
+	errNotPublished          = errors.New("questionnaire has not been published")
+	errDraftNotFound         = errors.New("draft not found")
+	errScheduleNotFound      = errors.New("schedule not found")
+	errSubmissionNotFound    = errors.New("submission not found")
+	errAlreadySubmitted      = errors.New("a response has already been submitted")
+	errSessionNotFound       = errors.New("session not found")
+	errRoomNotFound          = errors.New("room not found")
+	errBookingNotFound       = errors.New("booking not found")
+	errRoomFull              = errors.New("the room is fully booked for this time")
+	errBookingOverlap        = errors.New("you already have a booking at this time")
 )
 
+// requestError is returned from inside transactions when the request itself
+// is invalid, so handlers can answer 400 instead of 500
+type requestError string
+
+func (e requestError) Error() string {
+	return string(e)
+}
+
+func isRequestError(err error) bool {
+	var re requestError
+	return errors.As(err, &re)
+}
+
+// checkKeyUnique verifies that no other record in a bucket of questions or
+// questionnaires uses the same stable key
+func checkKeyUnique(b *bolt.Bucket, key, id string) error {
+	if key == "" {
+		return nil
+	}
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var record struct {
+			ID  string `json:"id"`
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal(v, &record); err != nil {
+			return err
+		}
+		if record.Key == key && record.ID != id {
+			return requestError("key already in use: " + key)
+		}
+	}
+	return nil
+}
+
 type DB struct {
 	*bolt.DB
 }
@@ -26,7 +97,7 @@ func newDB(path string) (*DB, error) {
 	}
 
 	err = db.Update(func(tx *bolt.Tx) error {
-		for _, bucket := range [][]byte{usersBucket, questionsBucket, submissionsBucket} {
+		for _, bucket := range [][]byte{usersBucket, questionsBucket, submissionsBucket, studiesBucket, secretsBucket, questionnairesBucket, questionnaireVersionsBucket, draftsBucket, schedulesBucket, participationBucket, sessionsBucket, idempotencyBucket, promptsBucket, roomsBucket, bookingsBucket} {
 			_, err := tx.CreateBucketIfNotExists(bucket)
 			if err != nil {
 				return err
@@ -123,25 +194,119 @@ func (db *DB) GetUserByEmail(email string) (*User, error) {
 	return &user, err
 }
 
-// Question methods
-func (db *DB) CreateQuestion(question *Question) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(questionsBucket)
+func (db *DB) SetUserRoles(id string, roles []string) (*User, error) {
+	var user User
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		v := b.Get([]byte(id))
+		if v == nil {
+			return errors.New("user not found")
+		}
+		if err := json.Unmarshal(v, &user); err != nil {
+			return err
+		}
+
+		user.Roles = roles
+
+		buf, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), buf)
+	})
+	return &user, err
+}
 
-		if question.ID == "" {
-			question.ID = uuid.New().String()
+func (db *DB) SetUserCohort(id, cohort string) (*User, error) {
+	var user User
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(usersBucket)
+		v := b.Get([]byte(id))
+		if v == nil {
+			return errors.New("user not found")
+		}
+		if err := json.Unmarshal(v, &user); err != nil {
+			return err
 		}
 
-		buf, err := json.Marshal(question)
+		user.Cohort = cohort
+
+		buf, err := json.Marshal(user)
 		if err != nil {
 			return err
 		}
+		return b.Put([]byte(id), buf)
+	})
+	return &user, err
+}
 
-		return b.Put([]byte(question.ID), buf)
+// Question methods
+func (db *DB) CreateQuestion(question *Question) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if err := checkQuestionCondition(tx, question); err != nil {
+			return err
+		}
+		return createQuestion(tx, question)
 	})
 }
 
+func createQuestion(tx *bolt.Tx, question *Question) error {
+	b := tx.Bucket(questionsBucket)
+
+	if question.ID == "" {
+		question.ID = uuid.New().String()
+	}
+
+	if err := checkKeyUnique(b, question.Key, question.ID); err != nil {
+		return err
+	}
+
+	// New questions go to the end of the list
+	position, err := nextQuestionPosition(b)
+	if err != nil {
+		return err
+	}
+	question.Position = position
+
+	buf, err := json.Marshal(question)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(question.ID), buf)
+}
+
+// nextQuestionPosition returns the position after the last question that is
+// not archived
+func nextQuestionPosition(b *bolt.Bucket) (int, error) {
+	position := 1
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var existing Question
+		if err := json.Unmarshal(v, &existing); err != nil {
+			return 0, err
+		}
+		if !existing.Archived && existing.Position >= position {
+			position = existing.Position + 1
+		}
+	}
+	return position, nil
+}
+
+// GetQuestions returns the questions that are not archived
 func (db *DB) GetQuestions() ([]Question, error) {
+	all, err := db.GetAllQuestions()
+	var questions []Question
+	for _, q := range all {
+		if !q.Archived {
+			questions = append(questions, q)
+		}
+	}
+	return questions, err
+}
+
+// GetAllQuestions returns every question, including archived ones
+func (db *DB) GetAllQuestions() ([]Question, error) {
 	var questions []Question
 	err := db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(questionsBucket)
@@ -156,63 +321,2062 @@ func (db *DB) GetQuestions() ([]Question, error) {
 		}
 		return nil
 	})
+	sortQuestions(questions)
 	return questions, err
 }
 
-// Submission methods
-func (db *DB) CreateSubmission(submission *Submission) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(submissionsBucket)
+func (db *DB) GetQuestion(id string) (*Question, error) {
+	var question Question
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(questionsBucket).Get([]byte(id))
+		if v == nil {
+			return errors.New("question not found")
+		}
+		return json.Unmarshal(v, &question)
+	})
+	return &question, err
+}
 
-		if submission.ID == "" {
-			submission.ID = uuid.New().String()
+// setQuestionArchived archives or restores a question. Archived questions
+// stay stored so historical submissions can still resolve them, and are
+// dropped from draft questionnaires; published versions keep them. Questions
+// other conditions refer to cannot be archived, nor questions restored while
+// their condition refers to archived ones.
+func (db *DB) setQuestionArchived(id string, archived bool, by string) (*Question, error) {
+	var question Question
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(questionsBucket)
+
+		v := b.Get([]byte(id))
+		if v == nil {
+			return errors.New("question not found")
+		}
+		if err := json.Unmarshal(v, &question); err != nil {
+			return err
 		}
 
-		submission.CreatedAt = time.Now()
+		if question.Archived == archived {
+			if archived {
+				return requestError("question is already archived")
+			}
+			return requestError("question is not archived")
+		}
 
-		buf, err := json.Marshal(submission)
+		question.Archived = archived
+		question.ArchivedBy = ""
+		question.ArchivedAt = nil
+		if archived {
+			now := time.Now()
+			question.ArchivedBy = by
+			question.ArchivedAt = &now
+			if err := checkConditionDependents(b, id); err != nil {
+				return err
+			}
+			if err := removeQuestionFromQuestionnaires(tx, id); err != nil {
+				return err
+			}
+		} else {
+			for _, ref := range conditionQuestionIDs(question.Condition) {
+				var target Question
+				if rv := b.Get([]byte(ref)); rv != nil {
+					if err := json.Unmarshal(rv, &target); err != nil {
+						return err
+					}
+				}
+				if target.ID == "" || target.Archived {
+					return requestError("condition refers to archived question " + ref + ", restore it first")
+				}
+			}
+			// Restored questions go to the end of the list
+			position, err := nextQuestionPosition(b)
+			if err != nil {
+				return err
+			}
+			question.Position = position
+		}
+
+		buf, err := json.Marshal(question)
 		if err != nil {
 			return err
 		}
+		return b.Put([]byte(id), buf)
+	})
+	return &question, err
+}
 
-		return b.Put([]byte(submission.ID), buf)
+func (db *DB) ArchiveQuestion(id, archivedBy string) (*Question, error) {
+	return db.setQuestionArchived(id, true, archivedBy)
+}
+
+func (db *DB) RestoreQuestion(id string) (*Question, error) {
+	return db.setQuestionArchived(id, false, "")
+}
+
+// sortQuestions orders questions by position. Questions created before
+// positions existed share position 0 and fall back to ID order.
+func sortQuestions(questions []Question) {
+	sort.SliceStable(questions, func(i, j int) bool {
+		if questions[i].Position != questions[j].Position {
+			return questions[i].Position < questions[j].Position
+		}
+		return questions[i].ID < questions[j].ID
 	})
 }
 
-func (db *DB) GetUserSubmissions(userID string) ([]Submission, error) {
-	var submissions []Submission
-	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(submissionsBucket)
-		c := b.Cursor()
+// ReorderQuestions rewrites the position of every question in one transaction.
+// ids must list every question that is not archived exactly once.
+func (db *DB) ReorderQuestions(ids []string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(questionsBucket)
 
+		if _, err := checkQuestionIDs(tx, ids); err != nil {
+			return err
+		}
+		n := 0
+		c := b.Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
-			var submission Submission
-			if err := json.Unmarshal(v, &submission); err != nil {
+			var question Question
+			if err := json.Unmarshal(v, &question); err != nil {
 				return err
 			}
-			if submission.UserID == userID {
-				submissions = append(submissions, submission)
+			if !question.Archived {
+				n++
+			}
+		}
+		if n != len(ids) {
+			return requestError(fmt.Sprintf("expected %d question IDs, got %d", n, len(ids)))
+		}
+
+		for i, id := range ids {
+			var question Question
+			if err := json.Unmarshal(b.Get([]byte(id)), &question); err != nil {
+				return err
+			}
+			question.Position = i + 1
+
+			buf, err := json.Marshal(question)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(id), buf); err != nil {
+				return err
 			}
 		}
 		return nil
 	})
-	return submissions, err
 }
 
-func (db *DB) GetAllSubmissions() ([]Submission, error) {
-	var submissions []Submission
+// Questionnaire methods
+
+// checkQuestionIDs verifies that every ID refers to an existing question that
+// is not archived and appears only once, and returns the questions
+func checkQuestionIDs(tx *bolt.Tx, ids []string) ([]Question, error) {
+	b := tx.Bucket(questionsBucket)
+	seen := make(map[string]bool)
+	var questions []Question
+	for _, id := range ids {
+		if seen[id] {
+			return nil, requestError("duplicate question: " + id)
+		}
+		seen[id] = true
+		v := b.Get([]byte(id))
+		if v == nil {
+			return nil, requestError("question not found: " + id)
+		}
+		var question Question
+		if err := json.Unmarshal(v, &question); err != nil {
+			return nil, err
+		}
+		if question.Archived {
+			return nil, requestError("question is archived: " + id)
+		}
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+// checkQuestionnaire verifies the questions of a questionnaire and that its
+// scoring rules only count those questions
+func checkQuestionnaire(tx *bolt.Tx, questionnaire *Questionnaire) error {
+	questions, err := checkQuestionIDs(tx, questionnaire.QuestionIDs)
+	if err != nil {
+		return err
+	}
+	if err := checkScoring(questionnaire.Scoring, questions); err != nil {
+		return requestError(err.Error())
+	}
+	return nil
+}
+
+func (db *DB) CreateQuestionnaire(questionnaire *Questionnaire) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		return createQuestionnaire(tx, questionnaire)
+	})
+}
+
+func createQuestionnaire(tx *bolt.Tx, questionnaire *Questionnaire) error {
+	b := tx.Bucket(questionnairesBucket)
+
+	if err := checkQuestionnaire(tx, questionnaire); err != nil {
+		return err
+	}
+
+	if questionnaire.ID == "" {
+		questionnaire.ID = uuid.New().String()
+	}
+
+	if err := checkKeyUnique(b, questionnaire.Key, questionnaire.ID); err != nil {
+		return err
+	}
+
+	questionnaire.CreatedAt = time.Now()
+	questionnaire.UpdatedAt = questionnaire.CreatedAt
+	questionnaire.PublishedVersion = 0
+
+	buf, err := json.Marshal(questionnaire)
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(questionnaire.ID), buf)
+}
+
+func (db *DB) GetQuestionnaire(id string) (*Questionnaire, error) {
+	var questionnaire Questionnaire
 	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket(submissionsBucket)
-		c := b.Cursor()
+		v := tx.Bucket(questionnairesBucket).Get([]byte(id))
+		if v == nil {
+			return errQuestionnaireNotFound
+		}
+		return json.Unmarshal(v, &questionnaire)
+	})
+	return &questionnaire, err
+}
+
+func (db *DB) GetQuestionnaireByKey(key string) (*Questionnaire, error) {
+	var questionnaire Questionnaire
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(questionnairesBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var q Questionnaire
+			if err := json.Unmarshal(v, &q); err != nil {
+				return err
+			}
+			if key != "" && q.Key == key {
+				questionnaire = q
+				return nil
+			}
+		}
+		return errQuestionnaireNotFound
+	})
+	return &questionnaire, err
+}
+
+func (db *DB) GetQuestionnaires() ([]Questionnaire, error) {
+	var questionnaires []Questionnaire
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(questionnairesBucket).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var questionnaire Questionnaire
+			if err := json.Unmarshal(v, &questionnaire); err != nil {
+				return err
+			}
+			questionnaires = append(questionnaires, questionnaire)
+		}
+		return nil
+	})
+	return questionnaires, err
+}
+
+func (db *DB) UpdateQuestionnaire(questionnaire *Questionnaire) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(questionnairesBucket)
+
+		v := b.Get([]byte(questionnaire.ID))
+		if v == nil {
+			return errQuestionnaireNotFound
+		}
+
+		var existing Questionnaire
+		if err := json.Unmarshal(v, &existing); err != nil {
+			return err
+		}
+
+		// Scoring is edited on its own, so leaving it out keeps the rules
+		if questionnaire.Scoring == nil {
+			questionnaire.Scoring = existing.Scoring
+		}
+		if err := checkQuestionnaire(tx, questionnaire); err != nil {
+			return err
+		}
+		if err := checkKeyUnique(b, questionnaire.Key, questionnaire.ID); err != nil {
+			return err
+		}
+
+		questionnaire.CreatedAt = existing.CreatedAt
+		questionnaire.UpdatedAt = time.Now()
+		questionnaire.PublishedVersion = existing.PublishedVersion
+		questionnaire.Throttle = existing.Throttle
+
+		buf, err := json.Marshal(questionnaire)
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(questionnaire.ID), buf)
+	})
+}
+
+// DeleteQuestionnaire deletes a questionnaire that has never been published
+// and has no submissions or schedules, so nothing refers to it
+func (db *DB) DeleteQuestionnaire(id string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(questionnairesBucket)
+
+		v := b.Get([]byte(id))
+		if v == nil {
+			return errQuestionnaireNotFound
+		}
+		var questionnaire Questionnaire
+		if err := json.Unmarshal(v, &questionnaire); err != nil {
+			return err
+		}
+		if questionnaire.PublishedVersion > 0 {
+			return requestError("published questionnaires cannot be deleted")
+		}
 
+		c := tx.Bucket(submissionsBucket).Cursor()
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			var submission Submission
 			if err := json.Unmarshal(v, &submission); err != nil {
 				return err
 			}
-			submissions = append(submissions, submission)
+			if submission.QuestionnaireID == id {
+				return requestError("questionnaires with submissions cannot be deleted")
+			}
 		}
-		return nil
+		c = tx.Bucket(schedulesBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var schedule Schedule
+			if err := json.Unmarshal(v, &schedule); err != nil {
+				return err
+			}
+			if schedule.QuestionnaireID == id {
+				return requestError("questionnaires with schedules cannot be deleted, delete the schedules first")
+			}
+		}
+
+		return b.Delete([]byte(id))
 	})
-	return submissions, err
+}
+
+// GetQuestionnaireQuestions returns the questions of a questionnaire in order
+func (db *DB) GetQuestionnaireQuestions(questionnaire *Questionnaire) ([]Question, error) {
+	questions := []Question{}
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(questionsBucket)
+
+		for _, id := range questionnaire.QuestionIDs {
+			v := b.Get([]byte(id))
+			if v == nil {
+				continue
+			}
+			var question Question
+			if err := json.Unmarshal(v, &question); err != nil {
+				return err
+			}
+			question.Position = len(questions) + 1
+			questions = append(questions, question)
+		}
+		return nil
+	})
+	return questions, err
+}
+
+// ReorderQuestionnaire replaces the question order of a questionnaire. ids
+// must be a permutation of its current questions.
+func (db *DB) ReorderQuestionnaire(id string, ids []string) (*Questionnaire, error) {
+	var questionnaire Questionnaire
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(questionnairesBucket)
+
+		v := b.Get([]byte(id))
+		if v == nil {
+			return errQuestionnaireNotFound
+		}
+		if err := json.Unmarshal(v, &questionnaire); err != nil {
+			return err
+		}
+
+		current := make(map[string]bool)
+		for _, qid := range questionnaire.QuestionIDs {
+			current[qid] = true
+		}
+		seen := make(map[string]bool)
+		for _, qid := range ids {
+			if !current[qid] {
+				return requestError("question is not part of the questionnaire: " + qid)
+			}
+			if seen[qid] {
+				return requestError("duplicate question: " + qid)
+			}
+			seen[qid] = true
+		}
+		if len(ids) != len(questionnaire.QuestionIDs) {
+			return requestError("every question of the questionnaire must be listed")
+		}
+
+		questionnaire.QuestionIDs = ids
+		questionnaire.UpdatedAt = time.Now()
+
+		buf, err := json.Marshal(questionnaire)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), buf)
+	})
+	return &questionnaire, err
+}
+
+// SetQuestionnaireScoring replaces the scoring rules of a questionnaire. They
+// apply to new submissions once the questionnaire is published again.
+func (db *DB) SetQuestionnaireScoring(id string, rules []ScoringRule) (*Questionnaire, error) {
+	var questionnaire Questionnaire
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(questionnairesBucket)
+
+		v := b.Get([]byte(id))
+		if v == nil {
+			return errQuestionnaireNotFound
+		}
+		if err := json.Unmarshal(v, &questionnaire); err != nil {
+			return err
+		}
+
+		questionnaire.Scoring = rules
+		if err := checkQuestionnaire(tx, &questionnaire); err != nil {
+			return err
+		}
+		questionnaire.UpdatedAt = time.Now()
+
+		buf, err := json.Marshal(questionnaire)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), buf)
+	})
+	return &questionnaire, err
+}
+
+func (db *DB) SetQuestionnaireThrottle(id string, rule *ThrottleRule) (*Questionnaire, error) {
+	var questionnaire Questionnaire
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(questionnairesBucket)
+
+		v := b.Get([]byte(id))
+		if v == nil {
+			return errQuestionnaireNotFound
+		}
+		if err := json.Unmarshal(v, &questionnaire); err != nil {
+			return err
+		}
+
+		questionnaire.Throttle = rule
+		questionnaire.UpdatedAt = time.Now()
+
+		buf, err := json.Marshal(questionnaire)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), buf)
+	})
+	return &questionnaire, err
+}
+
+// ImportQuestionnaire creates or updates the questions and questionnaire of a
+// validated document in one transaction. Records are matched by key, or by
+// ID for documents exported from records without a key. Records that already
+// match the document are left untouched, and archived questions have to be
+// restored first. keepScoring keeps the scoring rules of an existing
+// questionnaire for formats that cannot carry them.
+func (db *DB) ImportQuestionnaire(doc *QuestionnaireDocument, keepScoring bool) (*ImportResult, error) {
+	result := &ImportResult{Created: []string{}, Updated: []string{}, Unchanged: []string{}}
+	err := db.Update(func(tx *bolt.Tx) error {
+		qb := tx.Bucket(questionsBucket)
+
+		existing := make(map[string]Question)
+		c := qb.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var question Question
+			if err := json.Unmarshal(v, &question); err != nil {
+				return err
+			}
+			if question.Key != "" {
+				existing[question.Key] = question
+			}
+			if _, ok := existing[question.ID]; !ok {
+				existing[question.ID] = question
+			}
+		}
+
+		ids := make(map[string]string)
+		for _, q := range doc.Questions {
+			if current, ok := existing[q.Key]; ok {
+				ids[q.Key] = current.ID
+			} else {
+				ids[q.Key] = uuid.New().String()
+			}
+		}
+
+		questionIDs := make([]string, 0, len(doc.Questions))
+		var updated []Question
+		for _, q := range doc.Questions {
+			key := q.Key
+			q.ID = ids[key]
+			q.Condition = mapCondition(q.Condition, ids)
+			questionIDs = append(questionIDs, q.ID)
+
+			current, ok := existing[key]
+			if !ok {
+				if err := createQuestion(tx, &q); err != nil {
+					return err
+				}
+				result.Created = append(result.Created, key)
+				continue
+			}
+
+			// A question matched by its ID keeps having no key
+			if current.Key == "" && key == current.ID {
+				q.Key = ""
+			}
+			if current.Archived {
+				return requestError("question " + key + " is archived, restore it first")
+			}
+			q.Position = current.Position
+			if err := checkKeyUnique(qb, q.Key, q.ID); err != nil {
+				return err
+			}
+
+			before, err := json.Marshal(current)
+			if err != nil {
+				return err
+			}
+			buf, err := json.Marshal(q)
+			if err != nil {
+				return err
+			}
+			if bytes.Equal(before, buf) {
+				result.Unchanged = append(result.Unchanged, key)
+				continue
+			}
+			if err := qb.Put([]byte(q.ID), buf); err != nil {
+				return err
+			}
+			result.Updated = append(result.Updated, key)
+			updated = append(updated, q)
+		}
+
+		if err := saveImportedQuestionnaire(tx, doc, questionIDs, ids, keepScoring, result); err != nil {
+			return err
+		}
+
+		// Updated questions must keep the conditions of other questions and
+		// drafts on them valid, checked once everything is written
+		for i := range updated {
+			if err := checkQuestionCondition(tx, &updated[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// saveImportedQuestionnaire creates or updates the questionnaire of an
+// imported document once its questions are stored
+func saveImportedQuestionnaire(tx *bolt.Tx, doc *QuestionnaireDocument, questionIDs []string, ids map[string]string, keepScoring bool, result *ImportResult) error {
+	questionnaire := Questionnaire{
+		Key:          doc.Key,
+		Title:        doc.Title,
+		Description:  doc.Description,
+		Purpose:      doc.Purpose,
+		QuestionIDs:  questionIDs,
+		Anonymous:    doc.Anonymous,
+		Translations: doc.Translations,
+		Scoring:      mapScoring(doc.Scoring, ids),
+		ShowScores:   doc.ShowScores,
+	}
+
+	b := tx.Bucket(questionnairesBucket)
+	var current *Questionnaire
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var q Questionnaire
+		if err := json.Unmarshal(v, &q); err != nil {
+			return err
+		}
+		if (q.Key != "" && q.Key == doc.Key) || (q.Key == "" && q.ID == doc.Key) {
+			current = &q
+			break
+		}
+	}
+
+	if current == nil {
+		if err := createQuestionnaire(tx, &questionnaire); err != nil {
+			return err
+		}
+		result.QuestionnaireID = questionnaire.ID
+		result.Questionnaire = "created"
+		return nil
+	}
+
+	if current.Key == "" {
+		questionnaire.Key = ""
+	}
+	if keepScoring {
+		questionnaire.Scoring = current.Scoring
+	}
+	questionnaire.ID = current.ID
+	questionnaire.CreatedAt = current.CreatedAt
+	questionnaire.UpdatedAt = current.UpdatedAt
+	questionnaire.PublishedVersion = current.PublishedVersion
+	questionnaire.Throttle = current.Throttle
+	result.QuestionnaireID = current.ID
+
+	if err := checkQuestionnaire(tx, &questionnaire); err != nil {
+		return err
+	}
+
+	before, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(questionnaire)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(before, buf) {
+		result.Questionnaire = "unchanged"
+		return nil
+	}
+
+	questionnaire.UpdatedAt = time.Now()
+	buf, err = json.Marshal(questionnaire)
+	if err != nil {
+		return err
+	}
+	result.Questionnaire = "updated"
+	return b.Put([]byte(questionnaire.ID), buf)
+}
+
+// Questionnaire versions are keyed by questionnaire ID and zero padded
+// version number, so a prefix scan returns them in order
+func versionKey(questionnaireID string, version int) []byte {
+	return []byte(fmt.Sprintf("%s/%08d", questionnaireID, version))
+}
+
+// PublishQuestionnaire snapshots the questionnaire and its current questions
+// as a new immutable version
+func (db *DB) PublishQuestionnaire(id, publishedBy string) (*QuestionnaireVersion, error) {
+	var version *QuestionnaireVersion
+	err := db.Update(func(tx *bolt.Tx) error {
+		var err error
+		version, err = publishQuestionnaire(tx, id, publishedBy)
+		return err
+	})
+	return version, err
+}
+
+func publishQuestionnaire(tx *bolt.Tx, id, publishedBy string) (*QuestionnaireVersion, error) {
+	b := tx.Bucket(questionnairesBucket)
+
+	v := b.Get([]byte(id))
+	if v == nil {
+		return nil, errQuestionnaireNotFound
+	}
+	var questionnaire Questionnaire
+	if err := json.Unmarshal(v, &questionnaire); err != nil {
+		return nil, err
+	}
+
+	version, err := snapshotQuestionnaire(tx, &questionnaire)
+	if err != nil {
+		return nil, err
+	}
+	version.Version = questionnaire.PublishedVersion + 1
+	version.PublishedAt = time.Now()
+	version.PublishedBy = publishedBy
+
+	vb := tx.Bucket(questionnaireVersionsBucket)
+	if questionnaire.PublishedVersion > 0 {
+		var previous QuestionnaireVersion
+		if err := json.Unmarshal(vb.Get(versionKey(id, questionnaire.PublishedVersion)), &previous); err != nil {
+			return nil, err
+		}
+		if d := diffVersions(&previous, version); d.empty() {
+			return nil, requestError(fmt.Sprintf("no changes since version %d", previous.Version))
+		}
+	}
+
+	buf, err := json.Marshal(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := vb.Put(versionKey(id, version.Version), buf); err != nil {
+		return nil, err
+	}
+
+	questionnaire.PublishedVersion = version.Version
+	buf, err = json.Marshal(questionnaire)
+	if err != nil {
+		return nil, err
+	}
+	return version, b.Put([]byte(id), buf)
+}
+
+// snapshotQuestionnaire copies the current draft of a questionnaire and its
+// questions into an unnumbered version, checking that it could be published
+func snapshotQuestionnaire(tx *bolt.Tx, questionnaire *Questionnaire) (*QuestionnaireVersion, error) {
+	if len(questionnaire.QuestionIDs) == 0 {
+		return nil, requestError("cannot publish a questionnaire without questions")
+	}
+
+	version := QuestionnaireVersion{
+		QuestionnaireID: questionnaire.ID,
+		Title:           questionnaire.Title,
+		Description:     questionnaire.Description,
+		Purpose:         questionnaire.Purpose,
+		Anonymous:       questionnaire.Anonymous,
+		Translations:    questionnaire.Translations,
+		Scoring:         questionnaire.Scoring,
+		ShowScores:      questionnaire.ShowScores,
+	}
+
+	qb := tx.Bucket(questionsBucket)
+	for i, qid := range questionnaire.QuestionIDs {
+		qv := qb.Get([]byte(qid))
+		if qv == nil {
+			return nil, requestError("question not found: " + qid)
+		}
+		var question Question
+		if err := json.Unmarshal(qv, &question); err != nil {
+			return nil, err
+		}
+		if question.Archived {
+			return nil, requestError("question is archived: " + qid)
+		}
+		question.Position = i + 1
+		version.Questions = append(version.Questions, question)
+	}
+	if err := checkConditions(version.Questions); err != nil {
+		return nil, requestError(err.Error())
+	}
+	if err := checkScoring(version.Scoring, version.Questions); err != nil {
+		return nil, requestError(err.Error())
+	}
+	return &version, nil
+}
+
+// PreviewQuestionnaire returns the draft of a questionnaire as it would be
+// published, without publishing it
+func (db *DB) PreviewQuestionnaire(id string) (*QuestionnaireVersion, error) {
+	var version *QuestionnaireVersion
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(questionnairesBucket).Get([]byte(id))
+		if v == nil {
+			return errQuestionnaireNotFound
+		}
+		var questionnaire Questionnaire
+		if err := json.Unmarshal(v, &questionnaire); err != nil {
+			return err
+		}
+
+		var err error
+		version, err = snapshotQuestionnaire(tx, &questionnaire)
+		return err
+	})
+	return version, err
+}
+
+func (db *DB) GetQuestionnaireVersion(id string, version int) (*QuestionnaireVersion, error) {
+	var v QuestionnaireVersion
+	err := db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(questionnaireVersionsBucket).Get(versionKey(id, version))
+		if data == nil {
+			return errVersionNotFound
+		}
+		return json.Unmarshal(data, &v)
+	})
+	return &v, err
+}
+
+// GetPublishedQuestionnaire returns the latest published version of a questionnaire
+func (db *DB) GetPublishedQuestionnaire(id string) (*QuestionnaireVersion, error) {
+	questionnaire, err := db.GetQuestionnaire(id)
+	if err != nil {
+		return nil, err
+	}
+	if questionnaire.PublishedVersion == 0 {
+		return nil, errNotPublished
+	}
+	return db.GetQuestionnaireVersion(id, questionnaire.PublishedVersion)
+}
+
+func (db *DB) GetQuestionnaireVersions(id string) ([]QuestionnaireVersion, error) {
+	var versions []QuestionnaireVersion
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(questionnaireVersionsBucket).Cursor()
+		prefix := []byte(id + "/")
+
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var version QuestionnaireVersion
+			if err := json.Unmarshal(v, &version); err != nil {
+				return err
+			}
+			versions = append(versions, version)
+		}
+		return nil
+	})
+	return versions, err
+}
+
+// CreatePublishedQuestionnaire stores new questions and a questionnaire made
+// of them, and publishes it as version 1, all in one transaction
+func (db *DB) CreatePublishedQuestionnaire(questions []Question, questionnaire *Questionnaire, publishedBy string) (*QuestionnaireVersion, error) {
+	var version *QuestionnaireVersion
+	err := db.Update(func(tx *bolt.Tx) error {
+		for i := range questions {
+			if err := createQuestion(tx, &questions[i]); err != nil {
+				return err
+			}
+		}
+		if err := createQuestionnaire(tx, questionnaire); err != nil {
+			return err
+		}
+
+		var err error
+		version, err = publishQuestionnaire(tx, questionnaire.ID, publishedBy)
+		return err
+	})
+	return version, err
+}
+
+// checkConditionDependents refuses archiving a question that the display
+// condition of another active question refers to
+func checkConditionDependents(b *bolt.Bucket, questionID string) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var question Question
+		if err := json.Unmarshal(v, &question); err != nil {
+			return err
+		}
+		if !question.Archived && containsString(conditionQuestionIDs(question.Condition), questionID) {
+			return requestError("question is used by the condition of question " + question.ID + ", change that condition first")
+		}
+	}
+	return nil
+}
+
+// checkQuestionCondition checks the display condition of a question against
+// the questions stored in the transaction that saves it. A saved question must
+// also keep the conditions of other questions on it valid, form no cycle and
+// keep coming after the questions it depends on in draft questionnaires.
+func checkQuestionCondition(tx *bolt.Tx, q *Question) error {
+	var questions []Question
+	questionMap := make(map[string]Question)
+	c := tx.Bucket(questionsBucket).Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var question Question
+		if err := json.Unmarshal(v, &question); err != nil {
+			return err
+		}
+		if !question.Archived {
+			questions = append(questions, question)
+			questionMap[question.ID] = question
+		}
+	}
+
+	if q.Condition != nil {
+		if err := validateCondition(q.Condition, questionMap, q.ID); err != nil {
+			return requestError(err.Error())
+		}
+	}
+	// New questions cannot be referred to yet
+	if q.ID == "" {
+		return nil
+	}
+	questionMap[q.ID] = *q
+
+	if conditionReaches(questionMap, q.Condition, q.ID, make(map[string]bool)) {
+		return requestError("conditions cannot form a cycle")
+	}
+	for _, other := range questions {
+		if other.ID == q.ID || !containsString(conditionQuestionIDs(other.Condition), q.ID) {
+			continue
+		}
+		if err := validateCondition(other.Condition, questionMap, other.ID); err != nil {
+			return requestError("condition of question " + other.ID + " would be invalid: " + err.Error())
+		}
+	}
+
+	c = tx.Bucket(questionnairesBucket).Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var questionnaire Questionnaire
+		if err := json.Unmarshal(v, &questionnaire); err != nil {
+			return err
+		}
+		if !containsString(questionnaire.QuestionIDs, q.ID) {
+			continue
+		}
+		var ordered []Question
+		for _, id := range questionnaire.QuestionIDs {
+			if question, ok := questionMap[id]; ok {
+				ordered = append(ordered, question)
+			}
+		}
+		if err := checkConditions(ordered); err != nil {
+			return requestError("questionnaire " + questionnaire.Title + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// removeQuestionFromQuestionnaires drops an archived question from every
+// questionnaire that lists it. Questionnaires whose scoring counts the
+// question must have their scoring changed first.
+func removeQuestionFromQuestionnaires(tx *bolt.Tx, questionID string) error {
+	b := tx.Bucket(questionnairesBucket)
+
+	var updated [][2][]byte
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var questionnaire Questionnaire
+		if err := json.Unmarshal(v, &questionnaire); err != nil {
+			return err
+		}
+
+		ids := questionnaire.QuestionIDs[:0]
+		for _, id := range questionnaire.QuestionIDs {
+			if id != questionID {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == len(questionnaire.QuestionIDs) {
+			continue
+		}
+		for _, rule := range questionnaire.Scoring {
+			for _, item := range rule.Items {
+				if item.QuestionID == questionID {
+					return requestError("question is counted by scoring rule " + rule.Name + " of questionnaire " + questionnaire.Title + ", change its scoring first")
+				}
+			}
+		}
+		questionnaire.QuestionIDs = ids
+		questionnaire.UpdatedAt = time.Now()
+
+		buf, err := json.Marshal(questionnaire)
+		if err != nil {
+			return err
+		}
+		updated = append(updated, [2][]byte{append([]byte(nil), k...), buf})
+	}
+
+	for _, kv := range updated {
+		if err := b.Put(kv[0], kv[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Submission methods
+func (db *DB) CreateSubmission(submission *Submission) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(submissionsBucket)
+
+		if submission.ID == "" {
+			submission.ID = uuid.New().String()
+		}
+
+		submission.CreatedAt = time.Now()
+
+		// Limit responses to one per user and window, or per questionnaire
+		// for anonymous questionnaires without a schedule
+		if scope := participationScope(submission); scope != "" {
+			p := tx.Bucket(participationBucket)
+			participant := submission.UserID
+			if submission.Anonymous {
+				secret, err := participationSecret(tx)
+				if err != nil {
+					return err
+				}
+				participant = participationToken(secret, scope, submission.UserID)
+			}
+			key := []byte(scope + "/" + participant)
+			if p.Get(key) != nil {
+				return errAlreadySubmitted
+			}
+			// The token of an anonymous participant must not lead back to
+			// the submission, so only identified participation records it
+			value := []byte(submission.ID)
+			if submission.Anonymous {
+				value = []byte("anonymous")
+			}
+			if err := p.Put(key, value); err != nil {
+				return err
+			}
+		}
+
+		if submission.Phase != "" {
+			if err := recordSession(tx, submission); err != nil {
+				return err
+			}
+		}
+
+		// Submitting a questionnaire completes any draft of it
+		if submission.QuestionnaireID != "" {
+			if err := tx.Bucket(draftsBucket).Delete(draftKey(submission.UserID, submission.QuestionnaireID)); err != nil {
+				return err
+			}
+		}
+
+		if submission.Anonymous {
+			// Only the day is kept so the time cannot be matched against
+			// request logs
+			submission.UserID = ""
+			submission.CreatedAt = submission.CreatedAt.UTC().Truncate(24 * time.Hour)
+		}
+
+		buf, err := json.Marshal(submission)
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(submission.ID), buf)
+	})
+}
+
+func (db *DB) GetUserSubmissions(userID string) ([]Submission, error) {
+	var submissions []Submission
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(submissionsBucket)
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var submission Submission
+			if err := json.Unmarshal(v, &submission); err != nil {
+				return err
+			}
+			if submission.UserID == userID {
+				submissions = append(submissions, submission)
+			}
+		}
+		return nil
+	})
+	return submissions, err
+}
+
+func (db *DB) GetAllSubmissions() ([]Submission, error) {
+	var submissions []Submission
+	err := db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(submissionsBucket)
+		c := b.Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var submission Submission
+			if err := json.Unmarshal(v, &submission); err != nil {
+				return err
+			}
+			submissions = append(submissions, submission)
+		}
+		return nil
+	})
+	return submissions, err
+}
+
+// RescoreSubmissions replaces the scores of the submissions of a
+// questionnaire in one transaction. Submissions for which score reports false
+// are left alone. It returns how many were rescored and how many skipped.
+func (db *DB) RescoreSubmissions(questionnaireID string, score func(*Submission) ([]Score, bool)) (int, int, error) {
+	n, skipped := 0, 0
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(submissionsBucket)
+
+		updated := make(map[string][]byte)
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var submission Submission
+			if err := json.Unmarshal(v, &submission); err != nil {
+				return err
+			}
+			if submission.QuestionnaireID != questionnaireID {
+				continue
+			}
+			scores, ok := score(&submission)
+			if !ok {
+				skipped++
+				continue
+			}
+			submission.Scores = scores
+			buf, err := json.Marshal(submission)
+			if err != nil {
+				return err
+			}
+			updated[string(k)] = buf
+		}
+
+		for k, buf := range updated {
+			if err := b.Put([]byte(k), buf); err != nil {
+				return err
+			}
+		}
+		n = len(updated)
+		return nil
+	})
+	return n, skipped, err
+}
+
+// AmendSubmission replaces the answers and scores of a submission, keeping
+// the replaced ones as a revision
+func (db *DB) AmendSubmission(id string, answers []Answer, scores []Score, amendedBy, reason string) (*Submission, error) {
+	var submission Submission
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(submissionsBucket)
+		v := b.Get([]byte(id))
+		if v == nil {
+			return errSubmissionNotFound
+		}
+		if err := json.Unmarshal(v, &submission); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		submission.Revisions = append(submission.Revisions, SubmissionRevision{
+			Answers:   submission.Answers,
+			Scores:    submission.Scores,
+			AmendedAt: now,
+			AmendedBy: amendedBy,
+			Reason:    reason,
+		})
+		submission.Answers = answers
+		submission.Scores = scores
+		submission.AmendedAt = &now
+
+		buf, err := json.Marshal(submission)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), buf)
+	})
+	return &submission, err
+}
+
+func (db *DB) GetSubmission(id string) (*Submission, error) {
+	var submission Submission
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(submissionsBucket).Get([]byte(id))
+		if v == nil {
+			return errSubmissionNotFound
+		}
+		return json.Unmarshal(v, &submission)
+	})
+	return &submission, err
+}
+
+// Session methods
+func sessionKey(userID, sessionID string) []byte {
+	return []byte(userID + "/" + sessionID)
+}
+
+// recordSession opens a session with a pre-session submission or completes
+// it with the post-session one. Each session has one submission per phase.
+func recordSession(tx *bolt.Tx, submission *Submission) error {
+	b := tx.Bucket(sessionsBucket)
+
+	var session Session
+	var existing *Session
+	if submission.SessionID != "" {
+		if v := b.Get(sessionKey(submission.UserID, submission.SessionID)); v != nil {
+			existing = &Session{}
+			if err := json.Unmarshal(v, existing); err != nil {
+				return err
+			}
+		}
+	}
+	if err := checkSessionPhase(existing, submission); err != nil {
+		return err
+	}
+
+	if submission.Phase == "pre" {
+		if submission.SessionID == "" {
+			submission.SessionID = uuid.New().String()
+		}
+		session = Session{
+			ID:              submission.SessionID,
+			UserID:          submission.UserID,
+			PreSubmissionID: submission.ID,
+			CreatedAt:       submission.CreatedAt,
+		}
+	} else {
+		session = *existing
+		session.PostSubmissionID = submission.ID
+		session.CompletedAt = &submission.CreatedAt
+	}
+
+	buf, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return b.Put(sessionKey(session.UserID, session.ID), buf)
+}
+
+func (db *DB) GetSession(userID, sessionID string) (*Session, error) {
+	var session Session
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(sessionsBucket).Get(sessionKey(userID, sessionID))
+		if v == nil {
+			return errSessionNotFound
+		}
+		return json.Unmarshal(v, &session)
+	})
+	return &session, err
+}
+
+// GetUserSessions returns the sessions of a user, most recent first
+func (db *DB) GetUserSessions(userID string) ([]Session, error) {
+	var sessions []Session
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(sessionsBucket).Cursor()
+		prefix := []byte(userID + "/")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var session Session
+			if err := json.Unmarshal(v, &session); err != nil {
+				return err
+			}
+			sessions = append(sessions, session)
+		}
+		return nil
+	})
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, err
+}
+
+// Secret methods
+func putNewSecret(b *bolt.Bucket, name string) ([]byte, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, b.Put([]byte(name), secret)
+}
+
+// getOrCreateSecret returns a named secret, creating it on first use
+func getOrCreateSecret(tx *bolt.Tx, name string) ([]byte, error) {
+	b := tx.Bucket(secretsBucket)
+	if v := b.Get([]byte(name)); v != nil {
+		return append([]byte(nil), v...), nil
+	}
+	return putNewSecret(b, name)
+}
+
+// Study methods
+func studySecretName(studyID string) string {
+	return "study:" + studyID
+}
+
+func (db *DB) CreateStudy(study *Study) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(studiesBucket)
+
+		if study.ID == "" {
+			study.ID = uuid.New().String()
+		}
+
+		study.CreatedAt = time.Now()
+		study.KeyRotatedAt = study.CreatedAt
+
+		if _, err := putNewSecret(tx.Bucket(secretsBucket), studySecretName(study.ID)); err != nil {
+			return err
+		}
+
+		buf, err := json.Marshal(study)
+		if err != nil {
+			return err
+		}
+
+		return b.Put([]byte(study.ID), buf)
+	})
+}
+
+func (db *DB) GetStudy(id string) (*Study, error) {
+	var study Study
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(studiesBucket).Get([]byte(id))
+		if v == nil {
+			return errors.New("study not found")
+		}
+		return json.Unmarshal(v, &study)
+	})
+	return &study, err
+}
+
+func (db *DB) GetStudies() ([]Study, error) {
+	var studies []Study
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(studiesBucket).Cursor()
+
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var study Study
+			if err := json.Unmarshal(v, &study); err != nil {
+				return err
+			}
+			studies = append(studies, study)
+		}
+		return nil
+	})
+	return studies, err
+}
+
+// GetStudyKey returns the HMAC key used to derive pseudonyms for a study
+func (db *DB) GetStudyKey(id string) ([]byte, error) {
+	var key []byte
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(secretsBucket).Get([]byte(studySecretName(id)))
+		if v == nil {
+			return errors.New("study not found")
+		}
+		key = append([]byte(nil), v...)
+		return nil
+	})
+	return key, err
+}
+
+// RotateStudyKey replaces the key of a study, which changes every pseudonym
+// issued for it
+func (db *DB) RotateStudyKey(id string) (*Study, error) {
+	var study Study
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(studiesBucket)
+		v := b.Get([]byte(id))
+		if v == nil {
+			return errors.New("study not found")
+		}
+		if err := json.Unmarshal(v, &study); err != nil {
+			return err
+		}
+
+		if _, err := putNewSecret(tx.Bucket(secretsBucket), studySecretName(id)); err != nil {
+			return err
+		}
+		study.KeyRotatedAt = time.Now()
+
+		buf, err := json.Marshal(study)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), buf)
+	})
+	return &study, err
+}
+
+// Draft methods
+func draftKey(userID, questionnaireID string) []byte {
+	return []byte(userID + "/" + questionnaireID)
+}
+
+// SaveDraft creates or replaces the draft of a user for a questionnaire and
+// extends its expiry
+func (db *DB) SaveDraft(draft *Draft) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(draftsBucket)
+		key := draftKey(draft.UserID, draft.QuestionnaireID)
+
+		now := time.Now()
+		draft.CreatedAt = now
+		if v := b.Get(key); v != nil {
+			var existing Draft
+			if err := json.Unmarshal(v, &existing); err != nil {
+				return err
+			}
+			if existing.ExpiresAt.After(now) {
+				draft.CreatedAt = existing.CreatedAt
+			}
+		}
+		draft.UpdatedAt = now
+		draft.ExpiresAt = now.Add(*draftTTL)
+
+		buf, err := json.Marshal(draft)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, buf)
+	})
+}
+
+// GetDraft returns a draft that has not expired
+func (db *DB) GetDraft(userID, questionnaireID string) (*Draft, error) {
+	var draft Draft
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(draftsBucket).Get(draftKey(userID, questionnaireID))
+		if v == nil {
+			return errDraftNotFound
+		}
+		if err := json.Unmarshal(v, &draft); err != nil {
+			return err
+		}
+		if draft.ExpiresAt.Before(time.Now()) {
+			return errDraftNotFound
+		}
+		return nil
+	})
+	return &draft, err
+}
+
+func (db *DB) GetUserDrafts(userID string) ([]Draft, error) {
+	var drafts []Draft
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(draftsBucket).Cursor()
+		prefix := []byte(userID + "/")
+		now := time.Now()
+
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var draft Draft
+			if err := json.Unmarshal(v, &draft); err != nil {
+				return err
+			}
+			if draft.ExpiresAt.After(now) {
+				drafts = append(drafts, draft)
+			}
+		}
+		return nil
+	})
+	return drafts, err
+}
+
+func (db *DB) DeleteDraft(userID, questionnaireID string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(draftsBucket)
+		key := draftKey(userID, questionnaireID)
+		if b.Get(key) == nil {
+			return errDraftNotFound
+		}
+		return b.Delete(key)
+	})
+}
+
+func (db *DB) DeleteExpiredDrafts() error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(draftsBucket)
+		now := time.Now()
+
+		var expired [][]byte
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var draft Draft
+			if err := json.Unmarshal(v, &draft); err != nil {
+				return err
+			}
+			if draft.ExpiresAt.Before(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Schedule methods
+func (db *DB) CreateSchedule(schedule *Schedule) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(questionnairesBucket).Get([]byte(schedule.QuestionnaireID)) == nil {
+			return requestError("questionnaire not found: " + schedule.QuestionnaireID)
+		}
+
+		schedule.ID = uuid.New().String()
+		schedule.CreatedAt = time.Now()
+
+		buf, err := json.Marshal(schedule)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(schedulesBucket).Put([]byte(schedule.ID), buf)
+	})
+}
+
+func (db *DB) GetSchedule(id string) (*Schedule, error) {
+	var schedule Schedule
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(schedulesBucket).Get([]byte(id))
+		if v == nil {
+			return errScheduleNotFound
+		}
+		return json.Unmarshal(v, &schedule)
+	})
+	return &schedule, err
+}
+
+func (db *DB) GetSchedules() ([]Schedule, error) {
+	var schedules []Schedule
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(schedulesBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var schedule Schedule
+			if err := json.Unmarshal(v, &schedule); err != nil {
+				return err
+			}
+			schedules = append(schedules, schedule)
+		}
+		return nil
+	})
+	return schedules, err
+}
+
+func (db *DB) UpdateSchedule(schedule *Schedule) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(schedulesBucket)
+
+		v := b.Get([]byte(schedule.ID))
+		if v == nil {
+			return errScheduleNotFound
+		}
+		if tx.Bucket(questionnairesBucket).Get([]byte(schedule.QuestionnaireID)) == nil {
+			return requestError("questionnaire not found: " + schedule.QuestionnaireID)
+		}
+
+		var existing Schedule
+		if err := json.Unmarshal(v, &existing); err != nil {
+			return err
+		}
+		schedule.CreatedAt = existing.CreatedAt
+
+		buf, err := json.Marshal(schedule)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(schedule.ID), buf)
+	})
+}
+
+func (db *DB) DeleteSchedule(id string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(schedulesBucket)
+		if b.Get([]byte(id)) == nil {
+			return errScheduleNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// Participation methods
+
+// participationScope names the window a submission counts against: the
+// schedule window it was made in, or the whole questionnaire for anonymous
+// questionnaires without a schedule. Other submissions are not limited.
+func participationScope(submission *Submission) string {
+	if submission.ScheduleID != "" && submission.WindowStart != nil {
+		return fmt.Sprintf("%s/%d", submission.ScheduleID, submission.WindowStart.Unix())
+	}
+	if submission.Anonymous && submission.QuestionnaireID != "" {
+		return submission.QuestionnaireID
+	}
+	return ""
+}
+
+const participationSecretName = "participation"
+
+// participationSecret returns the key anonymous participation tokens are
+// derived with, creating it on first use
+func participationSecret(tx *bolt.Tx) ([]byte, error) {
+	return getOrCreateSecret(tx, participationSecretName)
+}
+
+// participationToken derives an anonymous participant's token for a scope.
+// Tokens differ between scopes, so they cannot be joined across windows.
+func participationToken(secret []byte, scope, userID string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(scope + "/" + userID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HasParticipated reports whether the user has already answered in the scope
+// the submission would count against
+func (db *DB) HasParticipated(submission *Submission) (bool, error) {
+	scope := participationScope(submission)
+	if scope == "" {
+		return false, nil
+	}
+
+	var participated bool
+	err := db.View(func(tx *bolt.Tx) error {
+		participant := submission.UserID
+		if submission.Anonymous {
+			secret := tx.Bucket(secretsBucket).Get([]byte(participationSecretName))
+			if secret == nil {
+				return nil
+			}
+			participant = participationToken(secret, scope, submission.UserID)
+		}
+		participated = tx.Bucket(participationBucket).Get([]byte(scope+"/"+participant)) != nil
+		return nil
+	})
+	return participated, err
+}
+
+// Idempotency methods
+
+const idempotencySecretName = "idempotency"
+
+// ReserveIdempotencyKey stores a pending record under a user's key unless an
+// unexpired record exists, in which case that record is returned instead.
+// Records are stored under an HMAC of the user and key, which is returned for
+// completing or releasing the reservation.
+func (db *DB) ReserveIdempotencyKey(userID, key string, record *IdempotencyRecord) (string, *IdempotencyRecord, error) {
+	var storageKey string
+	var existing *IdempotencyRecord
+	err := db.Update(func(tx *bolt.Tx) error {
+		secret, err := getOrCreateSecret(tx, idempotencySecretName)
+		if err != nil {
+			return err
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(userID + "/" + key))
+		storageKey = hex.EncodeToString(mac.Sum(nil))
+
+		b := tx.Bucket(idempotencyBucket)
+		if v := b.Get([]byte(storageKey)); v != nil {
+			var stored IdempotencyRecord
+			if err := json.Unmarshal(v, &stored); err != nil {
+				return err
+			}
+			if stored.ExpiresAt.After(time.Now()) {
+				existing = &stored
+				return nil
+			}
+		}
+
+		buf, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(storageKey), buf)
+	})
+	return storageKey, existing, err
+}
+
+func (db *DB) CompleteIdempotencyKey(key string, record *IdempotencyRecord) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		buf, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(idempotencyBucket).Put([]byte(key), buf)
+	})
+}
+
+// ReleaseIdempotencyKey forgets a key so the request can be retried
+func (db *DB) ReleaseIdempotencyKey(key string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(idempotencyBucket).Delete([]byte(key))
+	})
+}
+
+func (db *DB) DeleteExpiredIdempotencyKeys() error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(idempotencyBucket)
+		now := time.Now()
+
+		var expired [][]byte
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var record IdempotencyRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if record.ExpiresAt.Before(now) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Prompt methods
+func promptPrefix(questionnaireID, userID string) []byte {
+	return []byte(questionnaireID + "/" + userID + "/")
+}
+
+// DecidePrompt decides whether to prompt a user for a questionnaire from
+// their earlier decisions for it, and logs the decision in the same
+// transaction so concurrent requests see each other's prompts. Decisions for
+// anonymous questionnaires are logged under a participant token, so the log
+// cannot be matched against the anonymous submissions.
+func (db *DB) DecidePrompt(questionnaireID, userID string, anonymous bool, decide func(history []PromptDecision) PromptDecision) (*PromptDecision, error) {
+	var decision PromptDecision
+	err := db.Update(func(tx *bolt.Tx) error {
+		if anonymous {
+			secret, err := participationSecret(tx)
+			if err != nil {
+				return err
+			}
+			userID = participationToken(secret, "prompt/"+questionnaireID, userID)
+		}
+
+		b := tx.Bucket(promptsBucket)
+		prefix := promptPrefix(questionnaireID, userID)
+
+		var history []PromptDecision
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var d PromptDecision
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			history = append(history, d)
+		}
+
+		decision = decide(history)
+		decision.ID = uuid.New().String()
+		decision.QuestionnaireID = questionnaireID
+		decision.UserID = userID
+
+		buf, err := json.Marshal(decision)
+		if err != nil {
+			return err
+		}
+		// Keys sort by time within a user's decisions
+		key := fmt.Sprintf("%s%020d/%s", prefix, decision.DecidedAt.UnixNano(), decision.ID)
+		return b.Put([]byte(key), buf)
+	})
+	return &decision, err
+}
+
+// GetPromptDecisions returns the logged prompt decisions of a questionnaire
+func (db *DB) GetPromptDecisions(questionnaireID string) ([]PromptDecision, error) {
+	var decisions []PromptDecision
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(promptsBucket).Cursor()
+		prefix := []byte(questionnaireID + "/")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var d PromptDecision
+			if err := json.Unmarshal(v, &d); err != nil {
+				return err
+			}
+			decisions = append(decisions, d)
+		}
+		return nil
+	})
+	sort.SliceStable(decisions, func(i, j int) bool {
+		return decisions[i].DecidedAt.Before(decisions[j].DecidedAt)
+	})
+	return decisions, err
+}
+
+// Room methods
+
+// checkRoomNameUnique verifies that no other room has the same name
+func checkRoomNameUnique(b *bolt.Bucket, name, id string) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var room Room
+		if err := json.Unmarshal(v, &room); err != nil {
+			return err
+		}
+		if strings.EqualFold(room.Name, name) && room.ID != id {
+			return requestError("room name already in use: " + name)
+		}
+	}
+	return nil
+}
+
+func (db *DB) CreateRoom(room *Room) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(roomsBucket)
+		if err := checkRoomNameUnique(b, room.Name, ""); err != nil {
+			return err
+		}
+
+		room.ID = uuid.New().String()
+		room.CreatedAt = time.Now()
+		room.UpdatedAt = room.CreatedAt
+
+		buf, err := json.Marshal(room)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(room.ID), buf)
+	})
+}
+
+func (db *DB) GetRoom(id string) (*Room, error) {
+	var room Room
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(roomsBucket).Get([]byte(id))
+		if v == nil {
+			return errRoomNotFound
+		}
+		return json.Unmarshal(v, &room)
+	})
+	return &room, err
+}
+
+func (db *DB) GetRooms() ([]Room, error) {
+	var rooms []Room
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(roomsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var room Room
+			if err := json.Unmarshal(v, &room); err != nil {
+				return err
+			}
+			rooms = append(rooms, room)
+		}
+		return nil
+	})
+	sort.Slice(rooms, func(i, j int) bool {
+		return strings.ToLower(rooms[i].Name) < strings.ToLower(rooms[j].Name)
+	})
+	return rooms, err
+}
+
+// UpdateRoom replaces a room. It stays active or inactive when active is nil.
+// Capacity cannot drop below the seats its upcoming bookings take at once.
+func (db *DB) UpdateRoom(room *Room, active *bool) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(roomsBucket)
+
+		v := b.Get([]byte(room.ID))
+		if v == nil {
+			return errRoomNotFound
+		}
+		if err := checkRoomNameUnique(b, room.Name, room.ID); err != nil {
+			return err
+		}
+
+		var existing Room
+		if err := json.Unmarshal(v, &existing); err != nil {
+			return err
+		}
+		if room.Capacity < existing.Capacity {
+			now := time.Now()
+			end := now
+			var upcoming []Booking
+			c := tx.Bucket(bookingsBucket).Cursor()
+			for k, v := c.First(); k != nil; k, v = c.Next() {
+				var booking Booking
+				if err := json.Unmarshal(v, &booking); err != nil {
+					return err
+				}
+				if booking.RoomID != room.ID || booking.CancelledAt != nil || !booking.End.After(now) {
+					continue
+				}
+				upcoming = append(upcoming, booking)
+				if booking.End.After(end) {
+					end = booking.End
+				}
+			}
+			if peak := peakSeats(upcoming, now, end); peak > room.Capacity {
+				return requestError(fmt.Sprintf("upcoming bookings take up to %d seats at once, cancel some of them before lowering the capacity", peak))
+			}
+		}
+
+		room.Active = existing.Active
+		if active != nil {
+			room.Active = *active
+		}
+		room.CreatedAt = existing.CreatedAt
+		room.UpdatedAt = time.Now()
+
+		buf, err := json.Marshal(room)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(room.ID), buf)
+	})
+}
+
+// DeleteRoom deletes a room without upcoming bookings. Rooms with upcoming
+// bookings can be deactivated instead.
+func (db *DB) DeleteRoom(id string) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(roomsBucket)
+		if b.Get([]byte(id)) == nil {
+			return errRoomNotFound
+		}
+
+		now := time.Now()
+		c := tx.Bucket(bookingsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var booking Booking
+			if err := json.Unmarshal(v, &booking); err != nil {
+				return err
+			}
+			if booking.RoomID == id && booking.CancelledAt == nil && booking.End.After(now) {
+				return requestError("the room has upcoming bookings, cancel them or deactivate the room")
+			}
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// Booking methods
+
+// peakSeats returns the most seats taken at once by bookings during a time
+// slot. Occupancy only rises where a booking starts, so it is enough to check
+// the start of the slot and the starts of the bookings within it.
+func peakSeats(bookings []Booking, start, end time.Time) int {
+	points := []time.Time{start}
+	for _, b := range bookings {
+		if b.Start.After(start) && b.Start.Before(end) {
+			points = append(points, b.Start)
+		}
+	}
+
+	peak := 0
+	for _, t := range points {
+		seats := 0
+		for _, b := range bookings {
+			if !b.Start.After(t) && b.End.After(t) {
+				seats += b.Seats
+			}
+		}
+		if seats > peak {
+			peak = seats
+		}
+	}
+	return peak
+}
+
+// CreateBooking books a room if it has enough free seats for the whole slot
+// and the user has no other booking overlapping it. Checking and storing in
+// one transaction keeps concurrent bookings from both taking the last seat.
+func (db *DB) CreateBooking(booking *Booking) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		v := tx.Bucket(roomsBucket).Get([]byte(booking.RoomID))
+		if v == nil {
+			return requestError("room not found: " + booking.RoomID)
+		}
+		var room Room
+		if err := json.Unmarshal(v, &room); err != nil {
+			return err
+		}
+		if !room.Active {
+			return requestError("room is not available for booking: " + room.Name)
+		}
+		if booking.Seats > room.Capacity {
+			return requestError(fmt.Sprintf("seats exceed the capacity of %s (%d)", room.Name, room.Capacity))
+		}
+
+		b := tx.Bucket(bookingsBucket)
+		var overlapping []Booking
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var existing Booking
+			if err := json.Unmarshal(v, &existing); err != nil {
+				return err
+			}
+			if existing.CancelledAt != nil || !existing.Start.Before(booking.End) || !existing.End.After(booking.Start) {
+				continue
+			}
+			if existing.UserID == booking.UserID {
+				return errBookingOverlap
+			}
+			if existing.RoomID == booking.RoomID {
+				overlapping = append(overlapping, existing)
+			}
+		}
+		if peakSeats(overlapping, booking.Start, booking.End)+booking.Seats > room.Capacity {
+			return errRoomFull
+		}
+
+		booking.ID = uuid.New().String()
+		booking.CreatedAt = time.Now()
+
+		buf, err := json.Marshal(booking)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(booking.ID), buf)
+	})
+}
+
+func (db *DB) GetBooking(id string) (*Booking, error) {
+	var booking Booking
+	err := db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bookingsBucket).Get([]byte(id))
+		if v == nil {
+			return errBookingNotFound
+		}
+		return json.Unmarshal(v, &booking)
+	})
+	return &booking, err
+}
+
+// GetBookings returns every booking ordered by start time
+func (db *DB) GetBookings() ([]Booking, error) {
+	var bookings []Booking
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bookingsBucket).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var booking Booking
+			if err := json.Unmarshal(v, &booking); err != nil {
+				return err
+			}
+			bookings = append(bookings, booking)
+		}
+		return nil
+	})
+	sort.Slice(bookings, func(i, j int) bool {
+		return bookings[i].Start.Before(bookings[j].Start)
+	})
+	return bookings, err
+}
+
+// CancelBooking cancels a booking that has not ended yet. With an owner
+// given, only that user's bookings can be cancelled.
+func (db *DB) CancelBooking(id, ownerID, cancelledBy string) (*Booking, error) {
+	var booking Booking
+	err := db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bookingsBucket)
+		v := b.Get([]byte(id))
+		if v == nil {
+			return errBookingNotFound
+		}
+		if err := json.Unmarshal(v, &booking); err != nil {
+			return err
+		}
+		if ownerID != "" && booking.UserID != ownerID {
+			return errBookingNotFound
+		}
+		if booking.CancelledAt != nil {
+			return requestError("booking is already cancelled")
+		}
+
+		now := time.Now()
+		if !booking.End.After(now) {
+			return requestError("booking has already ended")
+		}
+		booking.CancelledAt = &now
+		booking.CancelledBy = cancelledBy
+
+		buf, err := json.Marshal(booking)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(booking.ID), buf)
+	})
+	return &booking, err
 }
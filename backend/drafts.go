@@ -0,0 +1,157 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func handleGetDrafts(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	drafts, err := db.GetUserDrafts(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch drafts"})
+		return
+	}
+
+	if drafts == nil {
+		drafts = []Draft{}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: DraftsResponse{
+			Drafts: drafts,
+		},
+	})
+}
+
+func handleGetDraft(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	draft, err := db.GetDraft(userID.(string), c.Param("questionnaire_id"))
+	if err != nil {
+		if errors.Is(err, errDraftNotFound) {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Draft not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch draft"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: draft})
+}
+
+// handleSaveDraft autosaves the answers given so far. The draft is pinned to
+// the questionnaire version that is published at the time of saving.
+func handleSaveDraft(c *gin.Context) {
+	userID, _ := c.Get("userID")
+	questionnaireID := c.Param("questionnaire_id")
+
+	var req DraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	version, err := db.GetPublishedQuestionnaire(questionnaireID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Questionnaire not found"})
+		return
+	}
+
+	// A draft ties answers to the user until it is submitted
+	if version.Anonymous {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Drafts are not kept for anonymous questionnaires"})
+		return
+	}
+
+	if err := applySessionPhase(&Submission{}, req.Phase, req.SessionID, version.Purpose); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	answers, errs := validateDraftAnswers(version.Questions, req.Answers)
+	if len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, GenericResponse{
+			Success: false,
+			Data: ValidationErrorsResponse{
+				Message: "Invalid answers",
+				Errors:  errs,
+			},
+		})
+		return
+	}
+
+	draft := &Draft{
+		UserID:          userID.(string),
+		QuestionnaireID: questionnaireID,
+		Version:         version.Version,
+		Phase:           req.Phase,
+		SessionID:       req.SessionID,
+		Answers:         answers,
+	}
+	if err := db.SaveDraft(draft); err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to save draft"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: draft})
+}
+
+func handleDeleteDraft(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	if err := db.DeleteDraft(userID.(string), c.Param("questionnaire_id")); err != nil {
+		if errors.Is(err, errDraftNotFound) {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Draft not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to delete draft"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: "Draft deleted successfully"})
+}
+
+// handleSubmitDraft promotes a draft to a submission of the version it was
+// saved against
+func handleSubmitDraft(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	draft, err := db.GetDraft(userID.(string), c.Param("questionnaire_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Draft not found"})
+		return
+	}
+
+	version, err := db.GetQuestionnaireVersion(draft.QuestionnaireID, draft.Version)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to validate questions"})
+		return
+	}
+
+	submission := &Submission{
+		UserID:          draft.UserID,
+		QuestionnaireID: draft.QuestionnaireID,
+		Version:         draft.Version,
+		Anonymous:       version.Anonymous,
+	}
+	if err := applySessionPhase(submission, draft.Phase, draft.SessionID, version.Purpose); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+	schedule, windowStart, err := resolveSchedule("", draft.QuestionnaireID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch schedules"})
+		return
+	}
+	if schedule != nil {
+		submission.ScheduleID = schedule.ID
+		submission.WindowStart = &windowStart
+	}
+	submitAnswers(c, submission, draft.Answers, version.Questions, version.Scoring)
+}
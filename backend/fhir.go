@@ -0,0 +1,631 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	fhirContentType = "application/fhir+json"
+
+	fhirMinValueURL       = "http://hl7.org/fhir/StructureDefinition/minValue"
+	fhirMaxValueURL       = "http://hl7.org/fhir/StructureDefinition/maxValue"
+	fhirItemControlURL    = "http://hl7.org/fhir/StructureDefinition/questionnaire-itemControl"
+	fhirItemControlSystem = "http://hl7.org/fhir/questionnaire-item-control"
+	fhirSliderStepURL     = "http://hl7.org/fhir/StructureDefinition/questionnaire-sliderStepValue"
+
+	// Elements FHIR has no place for are carried in tiramisu extensions
+	fhirKeySystem   = "urn:tiramisu:questionnaire-key"
+	fhirUserSystem  = "urn:tiramisu:user"
+	fhirPurposeURL  = "urn:tiramisu:fhir:purpose"
+	fhirMinLabelURL = "urn:tiramisu:fhir:min-label"
+	fhirMaxLabelURL = "urn:tiramisu:fhir:max-label"
+)
+
+var fhirOperators = map[string]string{
+	"eq":       "=",
+	"ne":       "!=",
+	"gt":       ">",
+	"gte":      ">=",
+	"lt":       "<",
+	"lte":      "<=",
+	"answered": "exists",
+}
+
+func fhirError(c *gin.Context, status int, code, message string) {
+	c.Render(status, fhirJSON{FHIROperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue:        []FHIRIssue{{Severity: "error", Code: code, Diagnostics: message}},
+	}})
+}
+
+func fhirResource(c *gin.Context, resource interface{}) {
+	c.Render(http.StatusOK, fhirJSON{resource})
+}
+
+// fhirJSON renders a resource with the FHIR media type
+type fhirJSON struct {
+	data interface{}
+}
+
+func (r fhirJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.data)
+}
+
+func (r fhirJSON) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", fhirContentType+"; charset=utf-8")
+}
+
+func fhirInteger(url string, v int) FHIRExtension {
+	return FHIRExtension{URL: url, ValueInteger: &v}
+}
+
+func findExtension(extensions []FHIRExtension, url string) *FHIRExtension {
+	for i := range extensions {
+		if extensions[i].URL == url {
+			return &extensions[i]
+		}
+	}
+	return nil
+}
+
+// fhirComparison maps a single condition comparison to an enableWhen
+func fhirComparison(cond *Condition, ref Question) (FHIREnableWhen, error) {
+	when := FHIREnableWhen{Question: cond.QuestionID, Operator: fhirOperators[cond.Operator]}
+	if cond.Operator == "answered" {
+		answered := true
+		when.AnswerBoolean = &answered
+		return when, nil
+	}
+
+	value, err := conditionValue(ref, cond.Operator, cond.Value)
+	if err != nil {
+		return when, err
+	}
+
+	if cond.Operator == "contains" {
+		// For repeating items FHIR's = holds when any answer matches
+		if ref.Type != "multi_choice" {
+			return when, errors.New("contains on text questions has no FHIR equivalent")
+		}
+		when.Operator = "="
+		when.AnswerCoding = &FHIRCoding{Code: value.(string)}
+		return when, nil
+	}
+
+	switch v := value.(type) {
+	case int:
+		when.AnswerInteger = &v
+	case bool:
+		when.AnswerBoolean = &v
+	case string:
+		if ref.Type == "choice" {
+			when.AnswerCoding = &FHIRCoding{Code: v}
+		} else {
+			when.AnswerString = &v
+		}
+	default:
+		return when, errors.New("comparing a multi_choice answer as a whole has no FHIR equivalent")
+	}
+	return when, nil
+}
+
+// fhirEnableWhen maps a display condition to enableWhen. FHIR only combines
+// comparisons with a single all or any, so deeper nesting cannot be exported.
+func fhirEnableWhen(cond *Condition, questions map[string]Question) ([]FHIREnableWhen, string, error) {
+	comparisons := []Condition{*cond}
+	behavior := ""
+	if len(cond.All) > 0 {
+		comparisons, behavior = cond.All, "all"
+	} else if len(cond.Any) > 0 {
+		comparisons, behavior = cond.Any, "any"
+	}
+
+	var whens []FHIREnableWhen
+	for i := range comparisons {
+		c := &comparisons[i]
+		if c.QuestionID == "" {
+			return nil, "", errors.New("nested all/any conditions have no FHIR equivalent")
+		}
+		when, err := fhirComparison(c, questions[c.QuestionID])
+		if err != nil {
+			return nil, "", err
+		}
+		whens = append(whens, when)
+	}
+	if len(whens) == 1 {
+		behavior = ""
+	}
+	return whens, behavior, nil
+}
+
+// toFHIRQuestionnaire maps a questionnaire document to a FHIR Questionnaire.
+// Question keys become link IDs.
+func toFHIRQuestionnaire(doc *QuestionnaireDocument, id string, version *QuestionnaireVersion) (*FHIRQuestionnaire, error) {
+	fq := &FHIRQuestionnaire{
+		ResourceType: "Questionnaire",
+		ID:           id,
+		Extension:    []FHIRExtension{{URL: fhirPurposeURL, ValueString: doc.Purpose}},
+		Identifier:   []FHIRIdentifier{{System: fhirKeySystem, Value: doc.Key}},
+		Title:        doc.Title,
+		Status:       "draft",
+		Description:  doc.Description,
+	}
+	if version != nil {
+		fq.Status = "active"
+		fq.Version = strconv.Itoa(version.Version)
+		fq.Date = version.PublishedAt.Format(time.RFC3339)
+	}
+
+	questions := make(map[string]Question)
+	for _, q := range doc.Questions {
+		questions[q.Key] = q
+
+		item := FHIRQuestionnaireItem{
+			LinkID:   q.Key,
+			Text:     q.Question,
+			Required: q.Required,
+		}
+		switch q.Type {
+		case "scale", "slider":
+			item.Type = "integer"
+			item.Extension = append(item.Extension, fhirInteger(fhirMinValueURL, q.Min), fhirInteger(fhirMaxValueURL, q.Max))
+			if q.Type == "slider" {
+				item.Extension = append(item.Extension,
+					FHIRExtension{URL: fhirItemControlURL, ValueCodeableConcept: &FHIRCodeableConcept{
+						Coding: []FHIRCoding{{System: fhirItemControlSystem, Code: "slider"}},
+					}},
+					fhirInteger(fhirSliderStepURL, q.Step))
+			}
+			if q.MinLabel != "" {
+				item.Extension = append(item.Extension, FHIRExtension{URL: fhirMinLabelURL, ValueString: q.MinLabel})
+			}
+			if q.MaxLabel != "" {
+				item.Extension = append(item.Extension, FHIRExtension{URL: fhirMaxLabelURL, ValueString: q.MaxLabel})
+			}
+		case "choice", "multi_choice":
+			item.Type = "choice"
+			item.Repeats = q.Type == "multi_choice"
+			for _, opt := range q.Options {
+				item.AnswerOption = append(item.AnswerOption, FHIRAnswerOption{
+					ValueCoding: &FHIRCoding{Code: opt.Value, Display: opt.Label},
+				})
+			}
+		case "text":
+			item.Type = "text"
+			item.MaxLength = q.MaxLength
+		case "boolean":
+			item.Type = "boolean"
+		}
+
+		if q.Condition != nil {
+			whens, behavior, err := fhirEnableWhen(q.Condition, questions)
+			if err != nil {
+				return nil, errors.New("condition of question " + q.Key + ": " + err.Error())
+			}
+			item.EnableWhen = whens
+			item.EnableBehavior = behavior
+		}
+		fq.Item = append(fq.Item, item)
+	}
+	return fq, nil
+}
+
+// flattenFHIRItems lists the question items of a Questionnaire in order,
+// taking them out of groups and leaving out display items
+func flattenFHIRItems(items []FHIRQuestionnaireItem) []FHIRQuestionnaireItem {
+	var flat []FHIRQuestionnaireItem
+	for _, item := range items {
+		switch item.Type {
+		case "group":
+			flat = append(flat, flattenFHIRItems(item.Item)...)
+		case "display":
+		default:
+			flat = append(flat, item)
+		}
+	}
+	return flat
+}
+
+// fromFHIRCondition maps enableWhen back to a display condition
+func fromFHIRCondition(item FHIRQuestionnaireItem, questions map[string]Question) (*Condition, error) {
+	var comparisons []Condition
+	for _, when := range item.EnableWhen {
+		ref, ok := questions[when.Question]
+		if !ok {
+			return nil, errors.New("enableWhen must refer to an earlier question: " + when.Question)
+		}
+
+		cond := Condition{QuestionID: when.Question}
+		for op, fhirOp := range fhirOperators {
+			if fhirOp == when.Operator {
+				cond.Operator = op
+			}
+		}
+
+		var value interface{}
+		switch {
+		case when.AnswerBoolean != nil:
+			value = *when.AnswerBoolean
+		case when.AnswerInteger != nil:
+			value = *when.AnswerInteger
+		case when.AnswerString != nil:
+			value = *when.AnswerString
+		case when.AnswerCoding != nil:
+			value = when.AnswerCoding.Code
+		default:
+			return nil, errors.New("unsupported enableWhen answer on " + when.Question)
+		}
+
+		switch {
+		case cond.Operator == "":
+			return nil, errors.New("unsupported enableWhen operator: " + when.Operator)
+		case cond.Operator == "answered":
+			if value != true {
+				return nil, errors.New("only exists = true is supported")
+			}
+			value = nil
+		case ref.Type == "multi_choice" && cond.Operator == "eq":
+			cond.Operator = "contains"
+		}
+
+		if value != nil {
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			cond.Value = raw
+		}
+		comparisons = append(comparisons, cond)
+	}
+
+	if len(comparisons) == 1 {
+		return &comparisons[0], nil
+	}
+	if item.EnableBehavior == "any" {
+		return &Condition{Any: comparisons}, nil
+	}
+	if item.EnableBehavior != "all" {
+		return nil, errors.New("enableBehavior is required with more than one enableWhen")
+	}
+	return &Condition{All: comparisons}, nil
+}
+
+// fromFHIRQuestionnaire maps a FHIR Questionnaire to a questionnaire document
+// that can be imported like any other
+func fromFHIRQuestionnaire(fq *FHIRQuestionnaire) (*QuestionnaireDocument, error) {
+	if fq.ResourceType != "Questionnaire" {
+		return nil, errors.New("resourceType must be Questionnaire")
+	}
+
+	doc := &QuestionnaireDocument{
+		Key:         fq.ID,
+		Title:       fq.Title,
+		Description: fq.Description,
+		Purpose:     "other",
+	}
+	for _, identifier := range fq.Identifier {
+		if identifier.System == fhirKeySystem {
+			doc.Key = identifier.Value
+		}
+	}
+	if ext := findExtension(fq.Extension, fhirPurposeURL); ext != nil {
+		doc.Purpose = ext.ValueString
+	}
+
+	questions := make(map[string]Question)
+	for _, item := range flattenFHIRItems(fq.Item) {
+		q := Question{
+			Key:      item.LinkID,
+			Question: item.Text,
+			Required: item.Required,
+		}
+
+		switch item.Type {
+		case "integer":
+			min := findExtension(item.Extension, fhirMinValueURL)
+			max := findExtension(item.Extension, fhirMaxValueURL)
+			if min == nil || max == nil || min.ValueInteger == nil || max.ValueInteger == nil {
+				return nil, errors.New("integer item " + item.LinkID + " needs minValue and maxValue extensions")
+			}
+			q.Type = "scale"
+			q.Min, q.Max = *min.ValueInteger, *max.ValueInteger
+			if control := findExtension(item.Extension, fhirItemControlURL); control != nil && control.ValueCodeableConcept != nil {
+				for _, coding := range control.ValueCodeableConcept.Coding {
+					if coding.Code == "slider" {
+						q.Type = "slider"
+						q.Step = 1
+					}
+				}
+			}
+			if step := findExtension(item.Extension, fhirSliderStepURL); q.Type == "slider" && step != nil && step.ValueInteger != nil {
+				q.Step = *step.ValueInteger
+			}
+			if label := findExtension(item.Extension, fhirMinLabelURL); label != nil {
+				q.MinLabel = label.ValueString
+			}
+			if label := findExtension(item.Extension, fhirMaxLabelURL); label != nil {
+				q.MaxLabel = label.ValueString
+			}
+		case "choice":
+			q.Type = "choice"
+			if item.Repeats {
+				q.Type = "multi_choice"
+			}
+			for _, opt := range item.AnswerOption {
+				switch {
+				case opt.ValueCoding != nil:
+					q.Options = append(q.Options, QuestionOption{Value: opt.ValueCoding.Code, Label: opt.ValueCoding.Display})
+				case opt.ValueString != nil:
+					q.Options = append(q.Options, QuestionOption{Value: *opt.ValueString})
+				case opt.ValueInteger != nil:
+					q.Options = append(q.Options, QuestionOption{Value: strconv.Itoa(*opt.ValueInteger)})
+				}
+			}
+		case "string", "text":
+			q.Type = "text"
+			q.MaxLength = item.MaxLength
+		case "boolean":
+			q.Type = "boolean"
+		default:
+			return nil, errors.New("unsupported item type " + item.Type + " of " + item.LinkID)
+		}
+
+		if len(item.EnableWhen) > 0 {
+			cond, err := fromFHIRCondition(item, questions)
+			if err != nil {
+				return nil, errors.New("item " + item.LinkID + ": " + err.Error())
+			}
+			q.Condition = cond
+		}
+
+		questions[q.Key] = q
+		doc.Questions = append(doc.Questions, q)
+	}
+	return doc, nil
+}
+
+// toFHIRQuestionnaireResponse maps a submission to a QuestionnaireResponse,
+// reading its answers against the questions it was made with
+func toFHIRQuestionnaireResponse(s *Submission, questions []Question) *FHIRQuestionnaireResponse {
+	qr := &FHIRQuestionnaireResponse{
+		ResourceType: "QuestionnaireResponse",
+		ID:           s.ID,
+		Status:       "completed",
+		Authored:     s.CreatedAt.Format(time.RFC3339),
+	}
+	if s.AmendedAt != nil {
+		qr.Status = "amended"
+	}
+	if s.QuestionnaireID != "" {
+		qr.Questionnaire = "Questionnaire/" + s.QuestionnaireID
+		if s.Version > 0 {
+			qr.Questionnaire += "|" + strconv.Itoa(s.Version)
+		}
+	}
+	if s.UserID != "" {
+		qr.Subject = &FHIRReference{Identifier: &FHIRIdentifier{System: fhirUserSystem, Value: s.UserID}}
+	}
+
+	answers := make(map[string]Answer)
+	for _, a := range s.Answers {
+		answers[a.ID] = a
+	}
+	for _, q := range questions {
+		a, ok := answers[q.ID]
+		if !ok {
+			continue
+		}
+		value, err := parseAnswerValue(q, a)
+		if err != nil || value == nil {
+			continue
+		}
+
+		linkID := q.Key
+		if linkID == "" {
+			linkID = q.ID
+		}
+		item := FHIRResponseItem{LinkID: linkID, Text: q.Question}
+		switch v := value.(type) {
+		case int:
+			item.Answer = []FHIRAnswer{{ValueInteger: &v}}
+		case bool:
+			item.Answer = []FHIRAnswer{{ValueBoolean: &v}}
+		case string:
+			if q.Type == "choice" {
+				item.Answer = []FHIRAnswer{{ValueCoding: &FHIRCoding{Code: v, Display: optionLabel(q, v)}}}
+			} else {
+				item.Answer = []FHIRAnswer{{ValueString: &v}}
+			}
+		case []string:
+			for _, code := range v {
+				item.Answer = append(item.Answer, FHIRAnswer{ValueCoding: &FHIRCoding{Code: code, Display: optionLabel(q, code)}})
+			}
+		}
+		qr.Item = append(qr.Item, item)
+	}
+	return qr
+}
+
+func optionLabel(q Question, value string) string {
+	for _, opt := range q.Options {
+		if opt.Value == value {
+			return opt.Label
+		}
+	}
+	return ""
+}
+
+// submissionQuestions returns the questions a submission was made with: its
+// questionnaire version, or the stored questions for submissions made before
+// questionnaires existed
+func submissionQuestions(s *Submission, versions map[string]*QuestionnaireVersion) ([]Question, error) {
+	if s.QuestionnaireID != "" && s.Version > 0 {
+		key := fmt.Sprintf("%s/%d", s.QuestionnaireID, s.Version)
+		if versions[key] == nil {
+			version, err := db.GetQuestionnaireVersion(s.QuestionnaireID, s.Version)
+			if err != nil {
+				return nil, err
+			}
+			versions[key] = version
+		}
+		return versions[key].Questions, nil
+	}
+
+	var questions []Question
+	for _, a := range s.Answers {
+		q, err := db.GetQuestion(a.ID)
+		if err != nil {
+			continue
+		}
+		questions = append(questions, *q)
+	}
+	return questions, nil
+}
+
+func handleGetFHIRQuestionnaire(c *gin.Context) {
+	questionnaire, err := findQuestionnaire(c.Param("id"))
+	if err != nil {
+		fhirError(c, http.StatusNotFound, "not-found", "Questionnaire not found")
+		return
+	}
+
+	// The latest published version, a requested one, or the draft while
+	// nothing is published
+	var version *QuestionnaireVersion
+	number := questionnaire.PublishedVersion
+	if v := c.Query("version"); v != "" {
+		if number, err = strconv.Atoi(v); err != nil {
+			fhirError(c, http.StatusBadRequest, "invalid", "Invalid version")
+			return
+		}
+	}
+
+	var doc *QuestionnaireDocument
+	if number > 0 {
+		if version, err = db.GetQuestionnaireVersion(questionnaire.ID, number); err != nil {
+			fhirError(c, http.StatusNotFound, "not-found", "Version not found")
+			return
+		}
+		snapshot := *questionnaire
+		snapshot.Title = version.Title
+		snapshot.Description = version.Description
+		snapshot.Purpose = version.Purpose
+		doc = exportQuestionnaire(&snapshot, version.Questions)
+	} else {
+		questions, err := db.GetQuestionnaireQuestions(questionnaire)
+		if err != nil {
+			fhirError(c, http.StatusInternalServerError, "exception", "Failed to fetch questions")
+			return
+		}
+		doc = exportQuestionnaire(questionnaire, questions)
+	}
+
+	fq, err := toFHIRQuestionnaire(doc, questionnaire.ID, version)
+	if err != nil {
+		fhirError(c, http.StatusUnprocessableEntity, "not-supported", err.Error())
+		return
+	}
+	fhirResource(c, fq)
+}
+
+// handleImportFHIRQuestionnaire creates or updates a questionnaire from a
+// FHIR Questionnaire. Scoring rules cannot be expressed in FHIR, so those of
+// an existing questionnaire are kept.
+func handleImportFHIRQuestionnaire(c *gin.Context) {
+	var fq FHIRQuestionnaire
+	if err := c.ShouldBindJSON(&fq); err != nil {
+		fhirError(c, http.StatusBadRequest, "structure", err.Error())
+		return
+	}
+
+	doc, err := fromFHIRQuestionnaire(&fq)
+	if err != nil {
+		fhirError(c, http.StatusUnprocessableEntity, "not-supported", err.Error())
+		return
+	}
+	if err := validateDocument(doc); err != nil {
+		fhirError(c, http.StatusUnprocessableEntity, "invalid", err.Error())
+		return
+	}
+
+	result, err := db.ImportQuestionnaire(doc, true)
+	if err != nil {
+		if isRequestError(err) {
+			fhirError(c, http.StatusUnprocessableEntity, "invalid", err.Error())
+		} else {
+			fhirError(c, http.StatusInternalServerError, "exception", "Failed to import questionnaire")
+		}
+		return
+	}
+
+	summary := fmt.Sprintf("Questionnaire %s %s", result.QuestionnaireID, result.Questionnaire)
+	for _, group := range []struct {
+		name string
+		keys []string
+	}{{"created", result.Created}, {"updated", result.Updated}, {"unchanged", result.Unchanged}} {
+		if len(group.keys) > 0 {
+			summary += "; " + group.name + ": " + strings.Join(group.keys, ", ")
+		}
+	}
+	fhirResource(c, FHIROperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue:        []FHIRIssue{{Severity: "information", Code: "informational", Diagnostics: summary}},
+	})
+}
+
+func handleGetFHIRQuestionnaireResponse(c *gin.Context) {
+	submission, err := db.GetSubmission(c.Param("id"))
+	if err != nil {
+		fhirError(c, http.StatusNotFound, "not-found", "Submission not found")
+		return
+	}
+
+	questions, err := submissionQuestions(submission, map[string]*QuestionnaireVersion{})
+	if err != nil {
+		fhirError(c, http.StatusInternalServerError, "exception", "Failed to fetch questions")
+		return
+	}
+	fhirResource(c, toFHIRQuestionnaireResponse(submission, questions))
+}
+
+// handleSearchFHIRQuestionnaireResponses returns the responses to a
+// questionnaire, or all responses, as a searchset Bundle
+func handleSearchFHIRQuestionnaireResponses(c *gin.Context) {
+	questionnaireID := strings.TrimPrefix(c.Query("questionnaire"), "Questionnaire/")
+	if i := strings.Index(questionnaireID, "|"); i >= 0 {
+		questionnaireID = questionnaireID[:i]
+	}
+
+	submissions, err := db.GetAllSubmissions()
+	if err != nil {
+		fhirError(c, http.StatusInternalServerError, "exception", "Failed to fetch submissions")
+		return
+	}
+
+	bundle := FHIRBundle{ResourceType: "Bundle", Type: "searchset", Entry: []FHIRBundleEntry{}}
+	versions := make(map[string]*QuestionnaireVersion)
+	for i := range submissions {
+		s := &submissions[i]
+		if questionnaireID != "" && s.QuestionnaireID != questionnaireID {
+			continue
+		}
+		questions, err := submissionQuestions(s, versions)
+		if err != nil {
+			fhirError(c, http.StatusInternalServerError, "exception", "Failed to fetch questions")
+			return
+		}
+		bundle.Entry = append(bundle.Entry, FHIRBundleEntry{Resource: toFHIRQuestionnaireResponse(s, questions)})
+	}
+	bundle.Total = len(bundle.Entry)
+
+	fhirResource(c, bundle)
+}
@@ -0,0 +1,268 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// supportedLocales returns the configured locales, always including the
+// default locale
+func supportedLocales() []string {
+	result := []string{*defaultLocale}
+	for _, l := range strings.Split(*locales, ",") {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l != "" && !containsString(result, l) {
+			result = append(result, l)
+		}
+	}
+	return result
+}
+
+// translationLocales returns the locales that need translations
+func translationLocales() []string {
+	return supportedLocales()[1:]
+}
+
+func isTranslationLocale(locale string) bool {
+	return containsString(translationLocales(), locale)
+}
+
+// matchLocale maps a language tag such as "fi-FI" to a supported locale
+func matchLocale(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	supported := supportedLocales()
+	if containsString(supported, tag) {
+		return tag
+	}
+	if i := strings.IndexAny(tag, "-_"); i > 0 && containsString(supported, tag[:i]) {
+		return tag[:i]
+	}
+	return ""
+}
+
+// parseAcceptLanguage returns the language tags of an Accept-Language header
+// ordered by preference
+func parseAcceptLanguage(header string) []string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if fields[0] == "" || fields[0] == "*" {
+			continue
+		}
+		q := 1.0
+		for _, f := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(f), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{fields[0], q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	names := make([]string, len(tags))
+	for i, t := range tags {
+		names[i] = t.name
+	}
+	return names
+}
+
+// requestLocale picks the locale for a request: the user's profile language
+// first, then the Accept-Language header, then the default locale
+func requestLocale(c *gin.Context) string {
+	var user *User
+	if userID, ok := c.Get("userID"); ok {
+		if u, err := db.GetUser(userID.(string)); err == nil {
+			user = u
+		}
+	}
+	return userLocale(user, c.GetHeader("Accept-Language"))
+}
+
+// userLocale picks the locale for a user, who may be nil, and the
+// Accept-Language header of their request
+func userLocale(user *User, acceptLanguage string) string {
+	if user != nil && user.Language != "" {
+		if l := matchLocale(user.Language); l != "" {
+			return l
+		}
+	}
+
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if l := matchLocale(tag); l != "" {
+			return l
+		}
+	}
+	return *defaultLocale
+}
+
+// localizeQuestion replaces the texts of a question with their translation,
+// falling back to the default text for anything untranslated
+func localizeQuestion(q Question, locale string) Question {
+	t, ok := q.Translations[locale]
+	q.Translations = nil
+	if !ok {
+		return q
+	}
+
+	if t.Question != "" {
+		q.Question = t.Question
+	}
+	if t.MinLabel != "" {
+		q.MinLabel = t.MinLabel
+	}
+	if t.MaxLabel != "" {
+		q.MaxLabel = t.MaxLabel
+	}
+	if len(q.Options) > 0 {
+		options := make([]QuestionOption, len(q.Options))
+		for i, opt := range q.Options {
+			if label := t.Options[opt.Value]; label != "" {
+				opt.Label = label
+			}
+			options[i] = opt
+		}
+		q.Options = options
+	}
+	return q
+}
+
+func localizeQuestions(questions []Question, locale string) []Question {
+	localized := make([]Question, len(questions))
+	for i, q := range questions {
+		localized[i] = localizeQuestion(q, locale)
+	}
+	return localized
+}
+
+func localizeVersion(v QuestionnaireVersion, locale string) QuestionnaireVersion {
+	if t, ok := v.Translations[locale]; ok {
+		if t.Title != "" {
+			v.Title = t.Title
+		}
+		if t.Description != "" {
+			v.Description = t.Description
+		}
+	}
+	v.Translations = nil
+	v.Questions = localizeQuestions(v.Questions, locale)
+	return v
+}
+
+func validateQuestionTranslations(q *Question) error {
+	for locale, t := range q.Translations {
+		if !isTranslationLocale(locale) {
+			return errors.New("unsupported translation locale: " + locale)
+		}
+		for value := range t.Options {
+			if !hasOption(*q, value) {
+				return errors.New("translation " + locale + " refers to unknown option: " + value)
+			}
+		}
+		if q.Type != "scale" && q.Type != "slider" && (t.MinLabel != "" || t.MaxLabel != "") {
+			return errors.New("only scale and slider questions have labels to translate")
+		}
+	}
+	return nil
+}
+
+func validateQuestionnaireTranslations(q *Questionnaire) error {
+	for locale := range q.Translations {
+		if !isTranslationLocale(locale) {
+			return errors.New("unsupported translation locale: " + locale)
+		}
+	}
+	return nil
+}
+
+// missingQuestionFields lists the texts of a question without a translation
+func missingQuestionFields(q Question, locale string) []string {
+	t := q.Translations[locale]
+	var fields []string
+	if t.Question == "" {
+		fields = append(fields, "question")
+	}
+	if q.MinLabel != "" && t.MinLabel == "" {
+		fields = append(fields, "min_label")
+	}
+	if q.MaxLabel != "" && t.MaxLabel == "" {
+		fields = append(fields, "max_label")
+	}
+	for _, opt := range q.Options {
+		if t.Options[opt.Value] == "" {
+			fields = append(fields, "options."+opt.Value)
+		}
+	}
+	return fields
+}
+
+func handleGetMissingTranslations(c *gin.Context) {
+	targets := translationLocales()
+	if locale := c.Query("locale"); locale != "" {
+		if !isTranslationLocale(locale) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Unsupported locale: " + locale})
+			return
+		}
+		targets = []string{locale}
+	}
+
+	var questions []Question
+	var questionnaire *Questionnaire
+	var err error
+	if id := c.Query("questionnaire"); id != "" {
+		if questionnaire, err = db.GetQuestionnaire(id); err != nil {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Questionnaire not found"})
+			return
+		}
+		questions, err = db.GetQuestionnaireQuestions(questionnaire)
+	} else {
+		questions, err = db.GetQuestions()
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch questions"})
+		return
+	}
+
+	missing := []MissingTranslation{}
+	for _, locale := range targets {
+		if questionnaire != nil {
+			t := questionnaire.Translations[locale]
+			var fields []string
+			if t.Title == "" {
+				fields = append(fields, "title")
+			}
+			if questionnaire.Description != "" && t.Description == "" {
+				fields = append(fields, "description")
+			}
+			if len(fields) > 0 {
+				missing = append(missing, MissingTranslation{Locale: locale, Fields: fields})
+			}
+		}
+		for _, q := range questions {
+			if fields := missingQuestionFields(q, locale); len(fields) > 0 {
+				missing = append(missing, MissingTranslation{QuestionID: q.ID, Locale: locale, Fields: fields})
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: MissingTranslationsResponse{
+			DefaultLocale: *defaultLocale,
+			Locales:       targets,
+			Missing:       missing,
+		},
+	})
+}
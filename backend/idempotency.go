@@ -0,0 +1,136 @@
+package backend
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	replayedHeader    = "Idempotent-Replayed"
+
+	// idempotencyPrivate is set by handlers whose response must not be kept,
+	// such as submissions to anonymous questionnaires
+	idempotencyPrivate = "idempotencyPrivate"
+)
+
+// responseRecorder keeps a copy of the response body as it is written
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyMiddleware makes POST requests carrying an Idempotency-Key safe
+// to retry. The first response is stored under the key, and a retry within
+// -idempotency-ttl gets that response back instead of repeating the request.
+// Keys are scoped to the user and stored as an HMAC over a server secret, so
+// a stored response cannot be looked up, or tied to its user, without the key
+// the client holds. Private responses keep only their status and the day they
+// were made.
+func idempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Idempotency-Key must be at most 255 characters"})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID, _ := c.Get("userID")
+		requestHash := sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.RequestURI()+"\n"), body...))
+
+		now := time.Now()
+		record := &IdempotencyRecord{
+			RequestHash: hex.EncodeToString(requestHash[:]),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(*idempotencyTTL),
+		}
+		storageKey, existing, err := db.ReserveIdempotencyKey(userID.(string), key, record)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to check Idempotency-Key"})
+			c.Abort()
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.RequestHash != record.RequestHash:
+				c.JSON(http.StatusUnprocessableEntity, GenericResponse{Success: false, Data: "Idempotency-Key was already used for a different request"})
+			case existing.Status == 0:
+				c.JSON(http.StatusConflict, GenericResponse{Success: false, Data: "A request with this Idempotency-Key is still in progress"})
+			case existing.Body == nil:
+				c.Header(replayedHeader, "true")
+				c.JSON(existing.Status, GenericResponse{Success: existing.Status < http.StatusBadRequest, Data: "Request already processed"})
+			default:
+				c.Header(replayedHeader, "true")
+				c.Data(existing.Status, existing.ContentType, existing.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		// A handler that panics never gets to complete the reservation, which
+		// would leave the key pending until it expires
+		completed := false
+		defer func() {
+			if !completed {
+				if err := db.ReleaseIdempotencyKey(storageKey); err != nil {
+					log.Println("Failed to release Idempotency-Key:", err)
+				}
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors are not stored so the request can be retried
+		if recorder.Status() >= http.StatusInternalServerError {
+			err = db.ReleaseIdempotencyKey(storageKey)
+		} else {
+			record.Status = recorder.Status()
+			if c.GetBool(idempotencyPrivate) {
+				// Kept until at least -idempotency-ttl after the end of the day
+				day := now.UTC().Truncate(24 * time.Hour)
+				record.CreatedAt = day
+				record.ExpiresAt = day.Add(24 * time.Hour).Add(*idempotencyTTL)
+			} else {
+				record.ContentType = recorder.Header().Get("Content-Type")
+				record.Body = recorder.body.Bytes()
+			}
+			err = db.CompleteIdempotencyKey(storageKey, record)
+		}
+		completed = true
+		if err != nil {
+			log.Println("Failed to store Idempotency-Key:", err)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package backend
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func instrumentItems(questions []Question, reversed ...int) []ScoringItem {
+	items := make([]ScoringItem, len(questions))
+	for i, q := range questions {
+		items[i] = ScoringItem{QuestionID: q.ID}
+	}
+	for _, n := range reversed {
+		items[n-1].Reverse = true
+	}
+	return items
+}
+
+func instrumentQuestions(key string, min, max int, minLabel, maxLabel string, texts ...string) []Question {
+	questions := make([]Question, len(texts))
+	for i, text := range texts {
+		questions[i] = Question{
+			ID:       fmt.Sprintf("%s-%d", key, i+1),
+			Question: text,
+			Type:     "scale",
+			Min:      min,
+			Max:      max,
+			MinLabel: minLabel,
+			MaxLabel: maxLabel,
+			Required: true,
+		}
+	}
+	return questions
+}
+
+var (
+	pss10Questions = instrumentQuestions("pss10", 0, 4, "Never", "Very often",
+		"In the last month, how often have you been upset because of something that happened unexpectedly?",
+		"In the last month, how often have you felt that you were unable to control the important things in your life?",
+		"In the last month, how often have you felt nervous and stressed?",
+		"In the last month, how often have you felt confident about your ability to handle your personal problems?",
+		"In the last month, how often have you felt that things were going your way?",
+		"In the last month, how often have you found that you could not cope with all the things that you had to do?",
+		"In the last month, how often have you been able to control irritations in your life?",
+		"In the last month, how often have you felt that you were on top of things?",
+		"In the last month, how often have you been angered because of things that happened that were outside of your control?",
+		"In the last month, how often have you felt difficulties were piling up so high that you could not overcome them?",
+	)
+	who5Questions = instrumentQuestions("who5", 0, 5, "At no time", "All of the time",
+		"Over the last two weeks, I have felt cheerful and in good spirits",
+		"Over the last two weeks, I have felt calm and relaxed",
+		"Over the last two weeks, I have felt active and vigorous",
+		"Over the last two weeks, I woke up feeling fresh and rested",
+		"Over the last two weeks, my daily life has been filled with things that interest me",
+	)
+	gad7Questions = instrumentQuestions("gad7", 0, 3, "Not at all", "Nearly every day",
+		"Over the last 2 weeks, how often have you been bothered by feeling nervous, anxious, or on edge?",
+		"Over the last 2 weeks, how often have you been bothered by not being able to stop or control worrying?",
+		"Over the last 2 weeks, how often have you been bothered by worrying too much about different things?",
+		"Over the last 2 weeks, how often have you been bothered by trouble relaxing?",
+		"Over the last 2 weeks, how often have you been bothered by being so restless that it is hard to sit still?",
+		"Over the last 2 weeks, how often have you been bothered by becoming easily annoyed or irritable?",
+		"Over the last 2 weeks, how often have you been bothered by feeling afraid, as if something awful might happen?",
+	)
+)
+
+// instruments is the library of validated questionnaires
+var instruments = []Instrument{
+	{
+		Key:         "pss10",
+		Title:       "Perceived Stress Scale (PSS-10)",
+		Description: "The questions in this scale ask you about your feelings and thoughts during the last month.",
+		Purpose:     "pulse",
+		Reference:   "Cohen, S., Kamarck, T., & Mermelstein, R. (1983). A global measure of perceived stress. Journal of Health and Social Behavior, 24, 386-396.",
+		Questions:   pss10Questions,
+		Scoring: []ScoringRule{{
+			Name:  "total",
+			Items: instrumentItems(pss10Questions, 4, 5, 7, 8),
+			Bands: []ScoreBand{
+				{Min: 0, Label: "low stress"},
+				{Min: 14, Label: "moderate stress"},
+				{Min: 27, Label: "high stress"},
+			},
+		}},
+	},
+	{
+		Key:         "who5",
+		Title:       "WHO-5 Well-Being Index",
+		Description: "Please indicate for each of the five statements which is closest to how you have been feeling over the last two weeks.",
+		Purpose:     "pulse",
+		Reference:   "World Health Organization (1998). Wellbeing Measures in Primary Health Care: The DepCare Project. WHO Regional Office for Europe.",
+		Questions:   who5Questions,
+		Scoring: []ScoringRule{{
+			Name:       "percentage",
+			Items:      instrumentItems(who5Questions),
+			Multiplier: 4,
+			Bands: []ScoreBand{
+				{Min: 0, Label: "likely depression"},
+				{Min: 29, Label: "poor wellbeing"},
+				{Min: 51, Label: "good wellbeing"},
+			},
+		}},
+	},
+	{
+		Key:         "gad7",
+		Title:       "Generalized Anxiety Disorder (GAD-7)",
+		Description: "Over the last 2 weeks, how often have you been bothered by the following problems?",
+		Purpose:     "pulse",
+		Reference:   "Spitzer, R. L., Kroenke, K., Williams, J. B., & Löwe, B. (2006). A brief measure for assessing generalized anxiety disorder. Archives of Internal Medicine, 166(10), 1092-1097.",
+		Questions:   gad7Questions,
+		Scoring: []ScoringRule{{
+			Name:  "total",
+			Items: instrumentItems(gad7Questions),
+			Bands: []ScoreBand{
+				{Min: 0, Label: "minimal anxiety"},
+				{Min: 5, Label: "mild anxiety"},
+				{Min: 10, Label: "moderate anxiety"},
+				{Min: 15, Label: "severe anxiety"},
+			},
+		}},
+	},
+}
+
+func findInstrument(key string) (Instrument, bool) {
+	for _, i := range instruments {
+		if i.Key == key {
+			return i, true
+		}
+	}
+	return Instrument{}, false
+}
+
+// instantiate copies the questions and scoring of an instrument with fresh
+// question IDs so it can be stored like any other questionnaire
+func (i Instrument) instantiate() ([]Question, *Questionnaire) {
+	ids := make(map[string]string)
+	questions := make([]Question, len(i.Questions))
+	questionnaire := &Questionnaire{
+		Title:       i.Title,
+		Description: i.Description,
+		Purpose:     i.Purpose,
+	}
+	for n, q := range i.Questions {
+		ids[q.ID] = uuid.New().String()
+		q.ID = ids[q.ID]
+		questions[n] = q
+		questionnaire.QuestionIDs = append(questionnaire.QuestionIDs, q.ID)
+	}
+
+	for _, rule := range i.Scoring {
+		items := make([]ScoringItem, len(rule.Items))
+		for n, item := range rule.Items {
+			item.QuestionID = ids[item.QuestionID]
+			items[n] = item
+		}
+		rule.Items = items
+		rule.Bands = append([]ScoreBand(nil), rule.Bands...)
+		questionnaire.Scoring = append(questionnaire.Scoring, rule)
+	}
+	return questions, questionnaire
+}
+
+func handleGetInstruments(c *gin.Context) {
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: InstrumentsResponse{
+			Instruments: instruments,
+		},
+	})
+}
+
+// handleInstantiateInstrument creates the questions and questionnaire of an
+// instrument and publishes it, so it can be answered right away
+func handleInstantiateInstrument(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	instrument, ok := findInstrument(c.Param("key"))
+	if !ok {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Instrument not found"})
+		return
+	}
+
+	questions, questionnaire := instrument.instantiate()
+	version, err := db.CreatePublishedQuestionnaire(questions, questionnaire, userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to create questionnaire"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: version})
+}
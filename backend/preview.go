@@ -0,0 +1,190 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// previewVersion loads the questionnaire version an admin previews: a
+// published version when ?version= is given, otherwise the draft as it
+// would be published next
+func previewVersion(c *gin.Context) (*QuestionnaireVersion, bool) {
+	id := c.Param("id")
+
+	if v := c.Query("version"); v != "" {
+		number, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Invalid version"})
+			return nil, false
+		}
+		version, err := db.GetQuestionnaireVersion(id, number)
+		if err != nil {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Version not found"})
+			return nil, false
+		}
+		return version, true
+	}
+
+	version, err := db.PreviewQuestionnaire(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, errQuestionnaireNotFound):
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to preview questionnaire"})
+		}
+		return nil, false
+	}
+	return version, true
+}
+
+// handlePreviewQuestionnaire returns a questionnaire as a user would receive
+// it. The locale is taken from ?locale=, or chosen for the user given by
+// ?user_id= the same way it would be for their own requests. Question texts
+// are rendered for that user and the session given in the query.
+func handlePreviewQuestionnaire(c *gin.Context) {
+	var user *User
+	if userID := c.Query("user_id"); userID != "" {
+		var err error
+		if user, err = db.GetUser(userID); err != nil {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "User not found"})
+			return
+		}
+	}
+
+	locale := ""
+	if l := c.Query("locale"); l != "" {
+		if locale = matchLocale(l); locale == "" {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Unsupported locale: " + l})
+			return
+		}
+	} else if user != nil {
+		locale = userLocale(user, c.GetHeader("Accept-Language"))
+	} else {
+		locale = requestLocale(c)
+	}
+
+	context, err := templateContext(c, user)
+	if err != nil {
+		if isRequestError(err) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch room"})
+		}
+		return
+	}
+
+	version, ok := previewVersion(c)
+	if !ok {
+		return
+	}
+
+	localized := localizeVersion(*version, locale)
+	localized.Questions = renderQuestions(localized.Questions, context)
+
+	c.Header("Content-Language", locale)
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: localized})
+}
+
+// handleDryRunQuestionnaire checks answers the way a submission would be
+// checked, including the schedule window, earlier participation, the session
+// phase, display conditions and scoring, without storing anything. The
+// submission is checked for the user given as user_id, by default the admin;
+// anonymous questionnaires are only checked for the admin.
+// Like the preview it runs against the draft unless ?version= is given.
+func handleDryRunQuestionnaire(c *gin.Context) {
+	adminID, _ := c.Get("userID")
+
+	var req DryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	version, ok := previewVersion(c)
+	if !ok {
+		return
+	}
+
+	result := DryRunResponse{
+		Errors:  []ValidationError{},
+		Answers: []Answer{},
+		Scores:  []Score{},
+	}
+
+	// Checking someone else's participation would reveal whether they
+	// answered an anonymous questionnaire
+	if req.UserID != "" && version.Anonymous {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "user_id cannot be used with anonymous questionnaires"})
+		return
+	}
+
+	userID := adminID.(string)
+	if req.UserID != "" {
+		if _, err := db.GetUser(req.UserID); err != nil {
+			result.Errors = append(result.Errors, ValidationError{Field: "user_id", Message: "user not found"})
+		}
+		userID = req.UserID
+	}
+
+	submission := &Submission{UserID: userID, QuestionnaireID: version.QuestionnaireID, Anonymous: version.Anonymous}
+	schedule, windowStart, err := resolveSchedule(req.ScheduleID, version.QuestionnaireID, time.Now())
+	if err != nil {
+		if !isRequestError(err) {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch schedules"})
+			return
+		}
+		result.Errors = append(result.Errors, ValidationError{Field: "schedule_id", Message: err.Error()})
+	}
+	if schedule != nil {
+		submission.ScheduleID = schedule.ID
+		submission.WindowStart = &windowStart
+	}
+
+	if err := applySessionPhase(submission, req.Phase, req.SessionID, version.Purpose); err != nil {
+		result.Errors = append(result.Errors, ValidationError{Field: "phase", Message: err.Error()})
+	} else if submission.Phase != "" {
+		var session *Session
+		if submission.SessionID != "" {
+			session, err = db.GetSession(userID, submission.SessionID)
+			if errors.Is(err, errSessionNotFound) {
+				session = nil
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch session"})
+				return
+			}
+		}
+		if err := checkSessionPhase(session, submission); err != nil {
+			result.Errors = append(result.Errors, ValidationError{Field: "session_id", Message: err.Error()})
+		}
+	}
+
+	participated, err := db.HasParticipated(submission)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to check participation"})
+		return
+	}
+	if participated {
+		result.Errors = append(result.Errors, ValidationError{Field: "questionnaire_id", Message: errAlreadySubmitted.Error()})
+	}
+
+	hidden, errs := evaluateSubmission(submission, req.Answers, version.Questions, version.Scoring)
+	result.Hidden = hidden
+	result.Errors = append(result.Errors, errs...)
+
+	result.Valid = len(result.Errors) == 0
+	if result.Valid {
+		result.Answers = submission.Answers
+		if submission.Scores != nil {
+			result.Scores = submission.Scores
+		}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: result})
+}
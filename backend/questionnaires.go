@@ -0,0 +1,255 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func validateQuestionnaire(q *Questionnaire) error {
+	q.Title = strings.TrimSpace(q.Title)
+	if q.Title == "" {
+		return errors.New("questionnaire title is required")
+	}
+
+	if err := validateKey(q.Key); err != nil {
+		return err
+	}
+
+	validPurpose := false
+	for _, p := range questionnairePurposes {
+		if q.Purpose == p {
+			validPurpose = true
+			break
+		}
+	}
+	if !validPurpose {
+		return errors.New("invalid questionnaire purpose")
+	}
+
+	if q.QuestionIDs == nil {
+		q.QuestionIDs = []string{}
+	}
+	return validateQuestionnaireTranslations(q)
+}
+
+// questionsFor returns the questions of the published version of a
+// questionnaire, or every question when no questionnaire is given
+func questionsFor(questionnaireID string) ([]Question, *QuestionnaireVersion, error) {
+	if questionnaireID == "" {
+		questions, err := db.GetQuestions()
+		return questions, nil, err
+	}
+
+	version, err := db.GetPublishedQuestionnaire(questionnaireID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return version.Questions, version, nil
+}
+
+func handleGetPublishedQuestionnaires(c *gin.Context) {
+	questionnaires, err := db.GetQuestionnaires()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch questionnaires"})
+		return
+	}
+
+	locale := requestLocale(c)
+	versions := []QuestionnaireVersion{}
+	for _, q := range questionnaires {
+		if q.PublishedVersion == 0 {
+			continue
+		}
+		version, err := db.GetQuestionnaireVersion(q.ID, q.PublishedVersion)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch questionnaires"})
+			return
+		}
+		if purpose := c.Query("purpose"); purpose != "" && version.Purpose != purpose {
+			continue
+		}
+		version.Questions = nil
+		versions = append(versions, localizeVersion(*version, locale))
+	}
+
+	c.Header("Content-Language", locale)
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: QuestionnaireVersionsResponse{
+			Versions: versions,
+		},
+	})
+}
+
+func handleGetPublishedQuestionnaire(c *gin.Context) {
+	version, err := db.GetPublishedQuestionnaire(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Questionnaire not found"})
+		return
+	}
+
+	userID, _ := c.Get("userID")
+	user, _ := db.GetUser(userID.(string))
+	context, err := templateContext(c, user)
+	if err != nil {
+		if isRequestError(err) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch room"})
+		}
+		return
+	}
+
+	locale := userLocale(user, c.GetHeader("Accept-Language"))
+	localized := localizeVersion(*version, locale)
+	localized.Questions = renderQuestions(localized.Questions, context)
+
+	c.Header("Content-Language", locale)
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: localized})
+}
+
+func handleGetQuestionnaires(c *gin.Context) {
+	questionnaires, err := db.GetQuestionnaires()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch questionnaires"})
+		return
+	}
+
+	if purpose := c.Query("purpose"); purpose != "" {
+		filtered := questionnaires[:0]
+		for _, q := range questionnaires {
+			if q.Purpose == purpose {
+				filtered = append(filtered, q)
+			}
+		}
+		questionnaires = filtered
+	}
+
+	if questionnaires == nil {
+		questionnaires = []Questionnaire{}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: QuestionnairesResponse{
+			Questionnaires: questionnaires,
+		},
+	})
+}
+
+func handleGetQuestionnaire(c *gin.Context) {
+	questionnaire, err := db.GetQuestionnaire(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Questionnaire not found"})
+		return
+	}
+
+	questions, err := db.GetQuestionnaireQuestions(questionnaire)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch questions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: QuestionnaireDetailResponse{
+			Questionnaire: *questionnaire,
+			Questions:     questions,
+		},
+	})
+}
+
+func handleCreateQuestionnaire(c *gin.Context) {
+	var questionnaire Questionnaire
+	if err := c.ShouldBindJSON(&questionnaire); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	questionnaire.ID = ""
+	if err := validateQuestionnaire(&questionnaire); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	if err := db.CreateQuestionnaire(&questionnaire); err != nil {
+		if isRequestError(err) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to create questionnaire"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: questionnaire})
+}
+
+func handleUpdateQuestionnaire(c *gin.Context) {
+	var questionnaire Questionnaire
+	if err := c.ShouldBindJSON(&questionnaire); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	questionnaire.ID = c.Param("id")
+	if err := validateQuestionnaire(&questionnaire); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	if err := db.UpdateQuestionnaire(&questionnaire); err != nil {
+		switch {
+		case errors.Is(err, errQuestionnaireNotFound):
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to update questionnaire"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: questionnaire})
+}
+
+func handleDeleteQuestionnaire(c *gin.Context) {
+	if err := db.DeleteQuestionnaire(c.Param("id")); err != nil {
+		switch {
+		case errors.Is(err, errQuestionnaireNotFound):
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to delete questionnaire"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: "Questionnaire deleted successfully"})
+}
+
+func handleReorderQuestionnaire(c *gin.Context) {
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	questionnaire, err := db.ReorderQuestionnaire(c.Param("id"), req.QuestionIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, errQuestionnaireNotFound):
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to reorder questionnaire"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: questionnaire})
+}
@@ -0,0 +1,196 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxRoomAmenities = 50
+	maxRoomPhotos    = 20
+)
+
+// validateRoom trims the texts of a room and drops empty and repeated
+// amenities and photos
+func validateRoom(r *Room) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("room name is required")
+	}
+	if utf8.RuneCountInString(r.Name) > 100 {
+		return errors.New("room name must be at most 100 characters")
+	}
+	r.Location = strings.TrimSpace(r.Location)
+	if utf8.RuneCountInString(r.Location) > 200 {
+		return errors.New("room location must be at most 200 characters")
+	}
+	r.AccessibilityNotes = strings.TrimSpace(r.AccessibilityNotes)
+	if utf8.RuneCountInString(r.AccessibilityNotes) > 2000 {
+		return errors.New("accessibility notes must be at most 2000 characters")
+	}
+	if r.Capacity < 1 {
+		return errors.New("room capacity must be at least 1")
+	}
+
+	amenities := []string{}
+	for _, a := range r.Amenities {
+		a = strings.TrimSpace(a)
+		if a != "" && !containsString(amenities, a) {
+			amenities = append(amenities, a)
+		}
+	}
+	if len(amenities) > maxRoomAmenities {
+		return errors.New("a room can have at most 50 amenities")
+	}
+	r.Amenities = amenities
+
+	photos := []string{}
+	for _, p := range r.Photos {
+		p = strings.TrimSpace(p)
+		if p == "" || containsString(photos, p) {
+			continue
+		}
+		u, err := url.Parse(p)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("photos must be http or https URLs: " + p)
+		}
+		photos = append(photos, p)
+	}
+	if len(photos) > maxRoomPhotos {
+		return errors.New("a room can have at most 20 photos")
+	}
+	r.Photos = photos
+	return nil
+}
+
+// handleGetRooms lists the active rooms for employees
+func handleGetRooms(c *gin.Context) {
+	rooms, err := db.GetRooms()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch rooms"})
+		return
+	}
+
+	active := []Room{}
+	for _, r := range rooms {
+		if r.Active {
+			active = append(active, r)
+		}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: RoomsResponse{
+			Rooms: active,
+		},
+	})
+}
+
+func handleGetRoom(c *gin.Context) {
+	room, err := db.GetRoom(c.Param("id"))
+	if err != nil || !room.Active {
+		if err == nil || errors.Is(err, errRoomNotFound) {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Room not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch room"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: room})
+}
+
+// handleGetAllRooms lists every room for admins, inactive ones included
+func handleGetAllRooms(c *gin.Context) {
+	rooms, err := db.GetRooms()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch rooms"})
+		return
+	}
+
+	if rooms == nil {
+		rooms = []Room{}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: RoomsResponse{
+			Rooms: rooms,
+		},
+	})
+}
+
+func handleCreateRoom(c *gin.Context) {
+	// Rooms are active unless created otherwise
+	room := Room{Active: true}
+	if err := c.ShouldBindJSON(&room); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	if err := validateRoom(&room); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	if err := db.CreateRoom(&room); err != nil {
+		if isRequestError(err) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to create room"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: room})
+}
+
+func handleUpdateRoom(c *gin.Context) {
+	var req RoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	room := req.Room
+	room.ID = c.Param("id")
+	if err := validateRoom(&room); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	if err := db.UpdateRoom(&room, req.Active); err != nil {
+		switch {
+		case errors.Is(err, errRoomNotFound):
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to update room"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: room})
+}
+
+func handleDeleteRoom(c *gin.Context) {
+	if err := db.DeleteRoom(c.Param("id")); err != nil {
+		switch {
+		case errors.Is(err, errRoomNotFound):
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to delete room"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: "Room deleted successfully"})
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/boltdb/bolt"
 	"github.com/gin-gonic/gin"
@@ -18,9 +19,12 @@ func initializeRoutes(router *gin.Engine) {
 	}
 
 	protected := router.Group("/api")
-	protected.Use(authMiddleware())
+	protected.Use(authMiddleware(), idempotencyMiddleware())
 	{
 		protected.GET("/questions", handleGetQuestions)
+		protected.GET("/questionnaires", handleGetPublishedQuestionnaires)
+		protected.GET("/questionnaires/:id", handleGetPublishedQuestionnaire)
+		protected.POST("/questionnaires/:id/prompt", handlePromptQuestionnaire)
 
 		// User profile
 		protected.GET("/profile", handleGetProfile)
@@ -28,6 +32,20 @@ func initializeRoutes(router *gin.Engine) {
 
 		// Questionnaire submissions
 		protected.POST("/submit", handleSubmitQuestionnaire)
+		protected.PUT("/submissions/:id", handleAmendOwnSubmission)
+		protected.GET("/drafts", handleGetDrafts)
+		protected.GET("/drafts/:questionnaire_id", handleGetDraft)
+		protected.PUT("/drafts/:questionnaire_id", handleSaveDraft)
+		protected.DELETE("/drafts/:questionnaire_id", handleDeleteDraft)
+		protected.POST("/drafts/:questionnaire_id/submit", handleSubmitDraft)
+		protected.GET("/surveys/pending", handleGetPendingSurveys)
+		protected.GET("/sessions", handleGetSessions)
+		protected.GET("/sessions/:id", handleGetSessionResult)
+		protected.GET("/rooms", handleGetRooms)
+		protected.GET("/rooms/:id", handleGetRoom)
+		protected.GET("/bookings", handleGetMyBookings)
+		protected.POST("/bookings", handleCreateBooking)
+		protected.DELETE("/bookings/:id", handleCancelOwnBooking)
 
 		// Admin routes
 		admin := protected.Group("/admin")
@@ -35,12 +53,68 @@ func initializeRoutes(router *gin.Engine) {
 		{
 			admin.GET("/submissions", handleGetUserSubmissions)
 			admin.GET("/submissions/:id", handleGetSubmission)
+			admin.PUT("/submissions/:id", handleAmendSubmission)
+			admin.GET("/questions", handleGetAllQuestions)
+			admin.GET("/questions/:id", handleGetQuestion)
 			admin.POST("/questions", handleCreateQuestion)
+			admin.POST("/questions/:id/restore", handleRestoreQuestion)
+			admin.PUT("/questions/order", handleReorderQuestions)
 			admin.PUT("/questions/:id", handleUpdateQuestion)
 			admin.DELETE("/questions/:id", handleDeleteQuestion)
 			admin.GET("/users", handleGetAllUsers)
 			admin.GET("/submissions/all", handleGetAllSubmissions)
+			admin.GET("/questionnaires", handleGetQuestionnaires)
+			admin.GET("/questionnaires/:id", handleGetQuestionnaire)
+			admin.POST("/questionnaires", handleCreateQuestionnaire)
+			admin.POST("/questionnaires/import", handleImportQuestionnaire)
+			admin.GET("/questionnaires/:id/export", handleExportQuestionnaire)
+			admin.PUT("/questionnaires/:id", handleUpdateQuestionnaire)
+			admin.PUT("/questionnaires/:id/order", handleReorderQuestionnaire)
+			admin.PUT("/questionnaires/:id/scoring", handleSetQuestionnaireScoring)
+			admin.PUT("/questionnaires/:id/throttle", handleSetQuestionnaireThrottle)
+			admin.GET("/questionnaires/:id/prompts", handleGetPromptDecisions)
+			admin.POST("/questionnaires/:id/rescore", handleRescoreQuestionnaire)
+			admin.POST("/questionnaires/:id/publish", handlePublishQuestionnaire)
+			admin.GET("/questionnaires/:id/versions", handleGetQuestionnaireVersions)
+			admin.GET("/questionnaires/:id/versions/:version", handleGetQuestionnaireVersion)
+			admin.GET("/questionnaires/:id/diff", handleDiffQuestionnaireVersions)
+			admin.GET("/questionnaires/:id/preview", handlePreviewQuestionnaire)
+			admin.POST("/questionnaires/:id/dry-run", handleDryRunQuestionnaire)
+			admin.GET("/translations/missing", handleGetMissingTranslations)
+			admin.GET("/fhir/Questionnaire/:id", handleGetFHIRQuestionnaire)
+			admin.POST("/fhir/Questionnaire", handleImportFHIRQuestionnaire)
+			admin.GET("/fhir/QuestionnaireResponse", handleSearchFHIRQuestionnaireResponses)
+			admin.GET("/fhir/QuestionnaireResponse/:id", handleGetFHIRQuestionnaireResponse)
+			admin.GET("/instruments", handleGetInstruments)
+			admin.POST("/instruments/:key", handleInstantiateInstrument)
+			admin.GET("/schedules", handleGetSchedules)
+			admin.POST("/schedules", handleCreateSchedule)
+			admin.PUT("/schedules/:id", handleUpdateSchedule)
+			admin.DELETE("/schedules/:id", handleDeleteSchedule)
+			admin.GET("/rooms", handleGetAllRooms)
+			admin.POST("/rooms", handleCreateRoom)
+			admin.PUT("/rooms/:id", handleUpdateRoom)
+			admin.DELETE("/rooms/:id", handleDeleteRoom)
+			admin.GET("/calendar", handleGetBookingCalendar)
+			admin.DELETE("/bookings/:id", handleCancelBooking)
+			admin.DELETE("/questionnaires/:id", handleDeleteQuestionnaire)
+			admin.PUT("/users/:id/roles", handleSetUserRoles)
+			admin.PUT("/users/:id/cohort", handleSetUserCohort)
+			admin.GET("/studies", handleGetStudies)
+			admin.POST("/studies", handleCreateStudy)
+			admin.POST("/studies/:id/rotate", handleRotateStudyKey)
 		}
+
+		// Analyst routes only ever see pseudonymous user identities
+		analyst := protected.Group("/analyst")
+		analyst.Use(roleMiddleware(roleAnalyst))
+		{
+			analyst.GET("/studies", handleGetStudies)
+			analyst.GET("/submissions", handleGetPseudonymousSubmissions)
+			analyst.GET("/questionnaires/:id/stats", handleGetQuestionnaireStats)
+		}
+
+		protected.POST("/reidentify", roleMiddleware(roleReidentifier), handleReidentify)
 	}
 }
 
@@ -162,11 +236,13 @@ func handleGetProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, GenericResponse{
 		Success: true,
 		Data: ProfileResponse{
-			ID:      user.ID,
-			Name:    user.Name,
-			Picture: user.Picture,
-			IsAdmin: user.IsAdmin,
-			Created: user.Created.String(),
+			ID:       user.ID,
+			Name:     user.Name,
+			Picture:  user.Picture,
+			IsAdmin:  user.IsAdmin,
+			Roles:    user.Roles,
+			Language: user.Language,
+			Created:  user.Created.String(),
 		},
 	})
 }
@@ -175,8 +251,9 @@ func handleUpdateProfile(c *gin.Context) {
 	userID, _ := c.Get("userID")
 
 	var updateReq struct {
-		Name    string `json:"name"`
-		Picture string `json:"picture"`
+		Name     string  `json:"name"`
+		Picture  string  `json:"picture"`
+		Language *string `json:"language"`
 	}
 
 	if err := c.ShouldBindJSON(&updateReq); err != nil {
@@ -184,6 +261,11 @@ func handleUpdateProfile(c *gin.Context) {
 		return
 	}
 
+	if updateReq.Language != nil && *updateReq.Language != "" && matchLocale(*updateReq.Language) == "" {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Unsupported language: " + *updateReq.Language})
+		return
+	}
+
 	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(usersBucket)
 
@@ -199,6 +281,9 @@ func handleUpdateProfile(c *gin.Context) {
 
 		user.Name = updateReq.Name
 		user.Picture = updateReq.Picture
+		if updateReq.Language != nil {
+			user.Language = matchLocale(*updateReq.Language)
+		}
 
 		buf, err := json.Marshal(user)
 		if err != nil {
@@ -217,16 +302,35 @@ func handleUpdateProfile(c *gin.Context) {
 }
 
 func handleGetQuestions(c *gin.Context) {
-	questions, err := db.GetQuestions()
+	questions, _, err := questionsFor(c.Query("questionnaire"))
+	if errors.Is(err, errQuestionnaireNotFound) || errors.Is(err, errNotPublished) {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Questionnaire not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch questions"})
 		return
 	}
 
+	userID, _ := c.Get("userID")
+	user, _ := db.GetUser(userID.(string))
+	context, err := templateContext(c, user)
+	if err != nil {
+		if isRequestError(err) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch room"})
+		}
+		return
+	}
+
+	locale := userLocale(user, c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", locale)
 	c.JSON(http.StatusOK, GenericResponse{
 		Success: true,
 		Data: QuestionsResponse{
-			Questions: questions,
+			Questions: renderQuestions(localizeQuestions(questions, locale), context),
+			Locale:    locale,
 		},
 	})
 }
@@ -238,25 +342,20 @@ func handleCreateQuestion(c *gin.Context) {
 		return
 	}
 
-	validType := false
-	for _, t := range questionTypes {
-		if question.Type == t {
-			validType = true
-			break
-		}
-	}
-	if !validType {
-		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Invalid question type"})
-		return
-	}
-
-	if question.Type == "scale" && (question.Min == 0 || question.Max == 0) {
-		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Scale questions require min and max values"})
+	// Questions are archived only through DELETE, which records who did it
+	question.ID = ""
+	question.Archived, question.ArchivedBy, question.ArchivedAt = false, "", nil
+	if err := validateQuestion(&question); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
 		return
 	}
 
 	if err := db.CreateQuestion(&question); err != nil {
-		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to create question"})
+		if isRequestError(err) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to create question"})
+		}
 		return
 	}
 
@@ -272,6 +371,12 @@ func handleUpdateQuestion(c *gin.Context) {
 		return
 	}
 
+	updateReq.ID = questionID
+	if err := validateQuestion(&updateReq); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
 	err := db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(questionsBucket)
 
@@ -280,19 +385,24 @@ func handleUpdateQuestion(c *gin.Context) {
 			return errors.New("question not found")
 		}
 
-		updateReq.ID = questionID
-
-		validType := false
-		for _, t := range questionTypes {
-			if updateReq.Type == t {
-				validType = true
-				break
-			}
+		var current Question
+		if err := json.Unmarshal(existing, &current); err != nil {
+			return err
+		}
+		if current.Archived {
+			return requestError("archived questions cannot be edited, restore it first")
 		}
-		if !validType {
-			return errors.New("invalid question type")
+		if err := checkKeyUnique(b, updateReq.Key, questionID); err != nil {
+			return err
+		}
+		if err := checkQuestionCondition(tx, &updateReq); err != nil {
+			return err
 		}
 
+		updateReq.ID = questionID
+		updateReq.Position = current.Position
+		updateReq.Archived, updateReq.ArchivedBy, updateReq.ArchivedAt = current.Archived, current.ArchivedBy, current.ArchivedAt
+
 		buf, err := json.Marshal(updateReq)
 		if err != nil {
 			return err
@@ -301,36 +411,123 @@ func handleUpdateQuestion(c *gin.Context) {
 	})
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: err.Error()})
+		switch {
+		case err.Error() == "question not found":
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to update question"})
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: updateReq})
 }
 
-func handleDeleteQuestion(c *gin.Context) {
-	questionID := c.Param("id")
+func handleReorderQuestions(c *gin.Context) {
+	var req ReorderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
 
-	err := db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(questionsBucket)
+	if err := db.ReorderQuestions(req.QuestionIDs); err != nil {
+		if isRequestError(err) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to reorder questions"})
+		}
+		return
+	}
 
-		if existing := b.Get([]byte(questionID)); existing == nil {
-			return errors.New("question not found")
+	questions, err := db.GetQuestions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch questions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: QuestionsResponse{
+			Questions: questions,
+		},
+	})
+}
+
+func handleGetAllQuestions(c *gin.Context) {
+	questions, err := db.GetAllQuestions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch questions"})
+		return
+	}
+
+	if c.Query("archived") == "true" {
+		archived := []Question{}
+		for _, q := range questions {
+			if q.Archived {
+				archived = append(archived, q)
+			}
 		}
+		questions = archived
+	}
 
-		return b.Delete([]byte(questionID))
+	if questions == nil {
+		questions = []Question{}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: QuestionsResponse{
+			Questions: questions,
+		},
 	})
+}
 
+func handleGetQuestion(c *gin.Context) {
+	question, err := db.GetQuestion(c.Param("id"))
 	if err != nil {
-		if err.Error() == "question not found" {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Question not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: question})
+}
+
+func handleDeleteQuestion(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	question, err := db.ArchiveQuestion(c.Param("id"), userID.(string))
+	if err != nil {
+		switch {
+		case err.Error() == "question not found":
 			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to delete question"})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to archive question"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: question})
+}
+
+func handleRestoreQuestion(c *gin.Context) {
+	question, err := db.RestoreQuestion(c.Param("id"))
+	if err != nil {
+		switch {
+		case err.Error() == "question not found":
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to restore question"})
 		}
 		return
 	}
 
-	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: "Question deleted successfully"})
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: question})
 }
 
 func handleSubmitQuestionnaire(c *gin.Context) {
@@ -342,41 +539,103 @@ func handleSubmitQuestionnaire(c *gin.Context) {
 		return
 	}
 
-	questions, err := db.GetQuestions()
+	submission := &Submission{UserID: userID.(string)}
+
+	schedule, windowStart, err := resolveSchedule(req.ScheduleID, req.QuestionnaireID, time.Now())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to validate questions"})
+		if isRequestError(err) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch schedules"})
+		}
 		return
 	}
+	if schedule != nil {
+		req.QuestionnaireID = schedule.QuestionnaireID
+		submission.ScheduleID = schedule.ID
+		submission.WindowStart = &windowStart
+	}
 
-	questionMap := make(map[string]Question)
-	for _, q := range questions {
-		questionMap[q.ID] = q
+	questions, version, err := questionsFor(req.QuestionnaireID)
+	if errors.Is(err, errQuestionnaireNotFound) {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Invalid questionnaire ID: " + req.QuestionnaireID})
+		return
+	}
+	if errors.Is(err, errNotPublished) {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Questionnaire has not been published"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to validate questions"})
+		return
 	}
 
-	for _, answer := range req.Answers {
-		if _, exists := questionMap[answer.ID]; !exists {
-			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Invalid question ID: " + answer.ID})
-			return
-		}
+	submission.QuestionnaireID = req.QuestionnaireID
+	var scoring []ScoringRule
+	purpose := ""
+	if version != nil {
+		submission.Version = version.Version
+		submission.Anonymous = version.Anonymous
+		scoring = version.Scoring
+		purpose = version.Purpose
+	}
+	if err := applySessionPhase(submission, req.Phase, req.SessionID, purpose); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
 	}
+	submitAnswers(c, submission, req.Answers, questions, scoring)
+}
+
+// evaluateSubmission validates answers against the questions being answered
+// and sets them, with their scores, on the prepared submission. It returns
+// the questions hidden by their display condition.
+func evaluateSubmission(submission *Submission, answers []Answer, questions []Question, scoring []ScoringRule) ([]string, []ValidationError) {
+	answers, hidden, errs := validateAnswers(questions, answers)
+	if len(errs) > 0 {
+		return hidden, errs
+	}
+	submission.Answers = answers
+	submission.Scores = computeScores(scoring, questions, answers)
+	return hidden, nil
+}
 
-	submission := &Submission{
-		UserID:  userID.(string),
-		Answers: req.Answers,
+// submitAnswers evaluates answers and stores them on the prepared submission
+func submitAnswers(c *gin.Context, submission *Submission, answers []Answer, questions []Question, scoring []ScoringRule) {
+	if _, errs := evaluateSubmission(submission, answers, questions, scoring); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, GenericResponse{
+			Success: false,
+			Data: ValidationErrorsResponse{
+				Message: "Invalid answers",
+				Errors:  errs,
+			},
+		})
+		return
 	}
 
+	if submission.Anonymous {
+		c.Set(idempotencyPrivate, true)
+	}
 	if err := db.CreateSubmission(submission); err != nil {
-		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to save submission"})
+		switch {
+		case errors.Is(err, errAlreadySubmitted):
+			c.JSON(http.StatusConflict, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to save submission"})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, GenericResponse{
-		Success: true,
-		Data: SubmissionResponse{
-			ID:        submission.ID,
-			CreatedAt: submission.CreatedAt,
-		},
-	})
+	response := SubmissionResponse{
+		ID:        submission.ID,
+		SessionID: submission.SessionID,
+		CreatedAt: submission.CreatedAt,
+	}
+	if scoresShown(submission, make(map[string]bool)) {
+		response.Scores = submission.Scores
+	}
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: response})
 }
 
 func handleGetSubmission(c *gin.Context) {
@@ -0,0 +1,318 @@
+package backend
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+func validateSchedule(s *Schedule) error {
+	if s.QuestionnaireID == "" {
+		return errors.New("questionnaire_id is required")
+	}
+
+	if !containsString(scheduleFrequencies, s.Frequency) {
+		return errors.New("invalid schedule frequency: " + s.Frequency)
+	}
+
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return errors.New("invalid timezone: " + s.Timezone)
+	}
+
+	if _, err := time.Parse("15:04", s.Time); err != nil {
+		return errors.New("time must be given as HH:MM")
+	}
+
+	// The longest window is the shortest gap between two occurrences, so a
+	// user can never be in two windows of the same schedule at once
+	maxHours := 24
+	switch s.Frequency {
+	case "daily":
+		s.Weekdays = nil
+	case "weekly":
+		if len(s.Weekdays) == 0 {
+			return errors.New("weekly schedules need at least one weekday")
+		}
+		days := make([]bool, 7)
+		for i, name := range s.Weekdays {
+			name = strings.ToLower(strings.TrimSpace(name))
+			day, ok := weekdayNames[name]
+			if !ok {
+				return errors.New("invalid weekday: " + s.Weekdays[i])
+			}
+			if days[day] {
+				return errors.New("duplicate weekday: " + name)
+			}
+			days[day] = true
+			s.Weekdays[i] = name
+		}
+		maxHours = 7 * 24
+		for d := 0; d < 7; d++ {
+			if !days[d] {
+				continue
+			}
+			for gap := 1; gap <= 7; gap++ {
+				if days[(d+gap)%7] {
+					if gap*24 < maxHours {
+						maxHours = gap * 24
+					}
+					break
+				}
+			}
+		}
+	}
+
+	if s.WindowHours < 1 || s.WindowHours > maxHours {
+		return errors.New("window_hours must be between 1 and the time between occurrences")
+	}
+
+	if s.StartsAt != nil && s.EndsAt != nil && !s.EndsAt.After(*s.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// occursOn reports whether the schedule has an occurrence on a day
+func (s *Schedule) occursOn(day time.Weekday) bool {
+	if s.Frequency == "daily" {
+		return true
+	}
+	for _, name := range s.Weekdays {
+		if weekdayNames[name] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// currentWindow returns the window of the latest occurrence at or before now
+// and whether that window is still open. Occurrences are computed in the
+// schedule's timezone so they follow daylight saving changes.
+func (s *Schedule) currentWindow(now time.Time) (time.Time, time.Time, bool) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	clock, err := time.Parse("15:04", s.Time)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	local := now.In(loc)
+	for days := 0; days <= 7; days++ {
+		day := local.AddDate(0, 0, -days)
+		if !s.occursOn(day.Weekday()) {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+		if start.After(now) {
+			continue
+		}
+		end := start.Add(time.Duration(s.WindowHours) * time.Hour)
+		open := !s.Paused && now.Before(end) &&
+			(s.StartsAt == nil || !start.Before(*s.StartsAt)) &&
+			(s.EndsAt == nil || start.Before(*s.EndsAt))
+		return start, end, open
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// resolveSchedule finds the schedule a submission answers. An explicit
+// schedule must have an open window. Without one, a submission to a
+// questionnaire falls under whichever of its schedules is open, so the one
+// submission per window limit cannot be bypassed by leaving schedule_id out.
+func resolveSchedule(scheduleID, questionnaireID string, now time.Time) (*Schedule, time.Time, error) {
+	if scheduleID != "" {
+		schedule, err := db.GetSchedule(scheduleID)
+		if err != nil {
+			if errors.Is(err, errScheduleNotFound) {
+				return nil, time.Time{}, requestError("invalid schedule ID: " + scheduleID)
+			}
+			return nil, time.Time{}, err
+		}
+		if questionnaireID != "" && questionnaireID != schedule.QuestionnaireID {
+			return nil, time.Time{}, requestError("questionnaire does not belong to the schedule")
+		}
+		start, _, open := schedule.currentWindow(now)
+		if !open {
+			return nil, time.Time{}, requestError("the survey window is closed")
+		}
+		return schedule, start, nil
+	}
+
+	if questionnaireID == "" {
+		return nil, time.Time{}, nil
+	}
+	schedules, err := db.GetSchedules()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	for _, s := range schedules {
+		if s.QuestionnaireID != questionnaireID {
+			continue
+		}
+		if start, _, open := s.currentWindow(now); open {
+			return &s, start, nil
+		}
+	}
+	return nil, time.Time{}, nil
+}
+
+// handleGetPendingSurveys lists the scheduled questionnaires whose window is
+// open and which the user has not answered in that window yet
+func handleGetPendingSurveys(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	schedules, err := db.GetSchedules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch surveys"})
+		return
+	}
+
+	locale := requestLocale(c)
+	now := time.Now()
+	surveys := []PendingSurvey{}
+	for _, s := range schedules {
+		start, end, open := s.currentWindow(now)
+		if !open {
+			continue
+		}
+
+		version, err := db.GetPublishedQuestionnaire(s.QuestionnaireID)
+		if err != nil {
+			// Schedules of unpublished or deleted questionnaires have nothing
+			// to answer
+			continue
+		}
+
+		participated, err := db.HasParticipated(&Submission{
+			UserID:      userID.(string),
+			Anonymous:   version.Anonymous,
+			ScheduleID:  s.ID,
+			WindowStart: &start,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch surveys"})
+			return
+		}
+		if participated {
+			continue
+		}
+
+		surveys = append(surveys, PendingSurvey{
+			ScheduleID:      s.ID,
+			QuestionnaireID: s.QuestionnaireID,
+			Title:           localizeVersion(*version, locale).Title,
+			WindowStart:     start,
+			WindowEnd:       end,
+		})
+	}
+
+	c.Header("Content-Language", locale)
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: PendingSurveysResponse{
+			Surveys: surveys,
+		},
+	})
+}
+
+func handleGetSchedules(c *gin.Context) {
+	schedules, err := db.GetSchedules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch schedules"})
+		return
+	}
+
+	if schedules == nil {
+		schedules = []Schedule{}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: SchedulesResponse{
+			Schedules: schedules,
+		},
+	})
+}
+
+func handleCreateSchedule(c *gin.Context) {
+	var schedule Schedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	if err := validateSchedule(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	if err := db.CreateSchedule(&schedule); err != nil {
+		if isRequestError(err) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to create schedule"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: schedule})
+}
+
+func handleUpdateSchedule(c *gin.Context) {
+	var schedule Schedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	schedule.ID = c.Param("id")
+	if err := validateSchedule(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	if err := db.UpdateSchedule(&schedule); err != nil {
+		switch {
+		case errors.Is(err, errScheduleNotFound):
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to update schedule"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: schedule})
+}
+
+func handleDeleteSchedule(c *gin.Context) {
+	if err := db.DeleteSchedule(c.Param("id")); err != nil {
+		if errors.Is(err, errScheduleNotFound) {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to delete schedule"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: "Schedule deleted successfully"})
+}
@@ -0,0 +1,310 @@
+package backend
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkScoring verifies that scoring rules only count numeric questions of
+// the questionnaire or scores defined before them, and that their bands are
+// in ascending order
+func checkScoring(rules []ScoringRule, questions []Question) error {
+	questionMap := make(map[string]Question)
+	for _, q := range questions {
+		questionMap[q.ID] = q
+	}
+
+	names := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			return errors.New("scoring rules need a name")
+		}
+		if names[rule.Name] {
+			return errors.New("duplicate scoring rule: " + rule.Name)
+		}
+
+		if rule.Method == "" {
+			rule.Method = "sum"
+		}
+		if !containsString(scoringMethods, rule.Method) {
+			return errors.New("invalid scoring method for " + rule.Name + ": " + rule.Method)
+		}
+
+		if len(rule.Items) == 0 {
+			return errors.New("scoring rule " + rule.Name + " has no items")
+		}
+		counted := make(map[string]bool)
+		for _, item := range rule.Items {
+			if (item.QuestionID == "") == (item.Score == "") {
+				return errors.New("items of scoring rule " + rule.Name + " need exactly one of question_id or score")
+			}
+			if rule.Method == "weighted" && item.Weight == 0 {
+				return errors.New("items of weighted scoring rule " + rule.Name + " need a weight")
+			}
+			if rule.Method != "weighted" && item.Weight != 0 {
+				return errors.New("only weighted scoring rules have item weights: " + rule.Name)
+			}
+
+			if item.Score != "" {
+				if !names[item.Score] {
+					return errors.New("scoring rule " + rule.Name + " must refer to an earlier score: " + item.Score)
+				}
+				if item.Reverse {
+					return errors.New("only questions can be reverse scored: " + item.Score)
+				}
+				if counted["score:"+item.Score] {
+					return errors.New("scoring rule " + rule.Name + " counts a score twice: " + item.Score)
+				}
+				counted["score:"+item.Score] = true
+				continue
+			}
+
+			q, ok := questionMap[item.QuestionID]
+			if !ok {
+				return errors.New("scoring rule " + rule.Name + " refers to a question not in the questionnaire: " + item.QuestionID)
+			}
+			if q.Type != "scale" && q.Type != "slider" {
+				return errors.New("scoring rule " + rule.Name + " can only count scale and slider questions: " + item.QuestionID)
+			}
+			if counted[item.QuestionID] {
+				return errors.New("scoring rule " + rule.Name + " counts a question twice: " + item.QuestionID)
+			}
+			counted[item.QuestionID] = true
+		}
+
+		if rule.Multiplier < 0 {
+			return errors.New("scoring rule " + rule.Name + " has a negative multiplier")
+		}
+		for i, band := range rule.Bands {
+			if band.Label == "" {
+				return errors.New("scoring rule " + rule.Name + " has a band without a label")
+			}
+			if i > 0 && band.Min <= rule.Bands[i-1].Min {
+				return errors.New("bands of scoring rule " + rule.Name + " must be in ascending order")
+			}
+		}
+		names[rule.Name] = true
+	}
+	return nil
+}
+
+// computeScores applies scoring rules to validated answers. A score is left
+// out when any of its items is unanswered, since a partial result would not
+// be comparable to the others, and so are scores derived from it.
+func computeScores(rules []ScoringRule, questions []Question, answers []Answer) []Score {
+	questionMap := make(map[string]Question)
+	for _, q := range questions {
+		questionMap[q.ID] = q
+	}
+	values := make(map[string]float64)
+	for _, a := range answers {
+		q, ok := questionMap[a.ID]
+		if !ok {
+			continue
+		}
+		if v, err := parseAnswerValue(q, a); err == nil {
+			if n, ok := v.(int); ok {
+				values[a.ID] = float64(n)
+			}
+		}
+	}
+
+	computed := make(map[string]float64)
+	var scores []Score
+	for _, rule := range rules {
+		total := 0.0
+		complete := true
+		for _, item := range rule.Items {
+			var v float64
+			var ok bool
+			if item.Score != "" {
+				v, ok = computed[item.Score]
+			} else {
+				v, ok = values[item.QuestionID]
+				if ok && item.Reverse {
+					q := questionMap[item.QuestionID]
+					v = float64(q.Max+q.Min) - v
+				}
+			}
+			if !ok {
+				complete = false
+				break
+			}
+			if rule.Method == "weighted" {
+				v *= item.Weight
+			}
+			total += v
+		}
+		if !complete {
+			continue
+		}
+
+		if rule.Method == "mean" {
+			total /= float64(len(rule.Items))
+		}
+		if rule.Multiplier != 0 {
+			total *= rule.Multiplier
+		}
+		total = math.Round(total*100) / 100
+		computed[rule.Name] = total
+
+		score := Score{Name: rule.Name, Value: total}
+		for _, band := range rule.Bands {
+			if total >= band.Min {
+				score.Band = band.Label
+			}
+		}
+		scores = append(scores, score)
+	}
+	return scores
+}
+
+// applicableRules returns the rules whose items are all part of a version's
+// questions, or scores of other applicable rules
+func applicableRules(rules []ScoringRule, questions []Question) []ScoringRule {
+	present := make(map[string]bool)
+	for _, q := range questions {
+		present[q.ID] = true
+	}
+
+	applicable := make(map[string]bool)
+	var result []ScoringRule
+	for _, rule := range rules {
+		ok := true
+		for _, item := range rule.Items {
+			if (item.Score != "" && !applicable[item.Score]) || (item.Score == "" && !present[item.QuestionID]) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			applicable[rule.Name] = true
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+// rescore recomputes the scores of a submission with rules that apply to the
+// questions of its version. Scores of the other rules are kept as they were,
+// and a submission no rule applies to is not rescored.
+func rescore(rules []ScoringRule, questions []Question, s *Submission) ([]Score, bool) {
+	applicable := applicableRules(rules, questions)
+	if len(applicable) == 0 {
+		return nil, false
+	}
+
+	isApplicable := make(map[string]bool)
+	for _, rule := range applicable {
+		isApplicable[rule.Name] = true
+	}
+	computed := make(map[string]Score)
+	for _, score := range computeScores(applicable, questions, s.Answers) {
+		computed[score.Name] = score
+	}
+	existing := make(map[string]Score)
+	for _, score := range s.Scores {
+		existing[score.Name] = score
+	}
+
+	var scores []Score
+	for _, rule := range rules {
+		score, ok := computed[rule.Name]
+		if !isApplicable[rule.Name] {
+			score, ok = existing[rule.Name]
+		}
+		if ok {
+			scores = append(scores, score)
+		}
+	}
+	return scores, true
+}
+
+func handleSetQuestionnaireScoring(c *gin.Context) {
+	var req ScoringRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	questionnaire, err := db.SetQuestionnaireScoring(c.Param("id"), req.Scoring)
+	if err != nil {
+		switch {
+		case errors.Is(err, errQuestionnaireNotFound):
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to update scoring"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: questionnaire})
+}
+
+// handleRescoreQuestionnaire recomputes the scores of every submission of a
+// questionnaire with the scoring rules of its latest published version.
+// Answers are read against the questions of the version each submission was
+// made with.
+func handleRescoreQuestionnaire(c *gin.Context) {
+	id := c.Param("id")
+
+	published, err := db.GetPublishedQuestionnaire(id)
+	if err != nil {
+		if errors.Is(err, errQuestionnaireNotFound) {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		}
+		return
+	}
+
+	versions, err := db.GetQuestionnaireVersions(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch versions"})
+		return
+	}
+	questions := make(map[int][]Question)
+	for _, v := range versions {
+		questions[v.Version] = v.Questions
+	}
+
+	n, skipped, err := db.RescoreSubmissions(id, func(s *Submission) ([]Score, bool) {
+		return rescore(published.Scoring, questions[s.Version], s)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to rescore submissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: RescoreResponse{
+			QuestionnaireID: id,
+			Version:         published.Version,
+			Rescored:        n,
+			Skipped:         skipped,
+		},
+	})
+}
+
+// scoresShown reports whether the user who made a submission may see its
+// scores. Each questionnaire version is looked up once and kept in shown.
+func scoresShown(s *Submission, shown map[string]bool) bool {
+	if s.QuestionnaireID == "" || s.Version == 0 {
+		return false
+	}
+	key := string(versionKey(s.QuestionnaireID, s.Version))
+	show, ok := shown[key]
+	if !ok {
+		version, err := db.GetQuestionnaireVersion(s.QuestionnaireID, s.Version)
+		show = err == nil && version.ShowScores
+		shown[key] = show
+	}
+	return show
+}
@@ -2,6 +2,8 @@ package backend
 
 import (
 	"flag"
+	"log"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -10,8 +12,19 @@ import (
 var (
 	router *gin.Engine
 	db     *DB
+
 	dbPath = flag.String("db", "tiramisu.db", "Path to the database file")
 	port   = flag.String("port", "8080", "Port to run the server on")
+
+	draftTTL       = flag.Duration("draft-ttl", 72*time.Hour, "How long an untouched questionnaire draft is kept")
+	amendWindow    = flag.Duration("amend-window", 15*time.Minute, "How long users can amend their own submissions")
+	idempotencyTTL = flag.Duration("idempotency-ttl", 24*time.Hour, "How long the response to a request with an Idempotency-Key is kept for retries")
+	maxBooking     = flag.Duration("max-booking", 4*time.Hour, "Longest time a room can be booked at once")
+
+	minGroupSize = flag.Int("min-group-size", 5, "Fewest submissions statistics are reported for")
+
+	defaultLocale = flag.String("default-locale", "en", "Locale of the untranslated question text")
+	locales       = flag.String("locales", "en,fi,sv,ro", "Comma separated list of supported locales")
 )
 
 func Main() {
@@ -27,11 +40,23 @@ func Main() {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Accept-Language", "Authorization", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Language", "Idempotent-Replayed"},
 		AllowCredentials: true,
 	}))
 
 	initializeRoutes(router)
 
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := db.DeleteExpiredDrafts(); err != nil {
+				log.Println("Failed to delete expired drafts:", err)
+			}
+			if err := db.DeleteExpiredIdempotencyKeys(); err != nil {
+				log.Println("Failed to delete expired idempotency keys:", err)
+			}
+		}
+	}()
+
 	router.Run(":" + *port)
 }
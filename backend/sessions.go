@@ -0,0 +1,204 @@
+package backend
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// applySessionPhase sets the phase and session of a submission. Questionnaires
+// meant for before or after a session imply their phase. A post-session
+// submission must name the session its pre-session submission opened.
+func applySessionPhase(submission *Submission, phase, sessionID, purpose string) error {
+	implied := map[string]string{"pre_session": "pre", "post_session": "post"}[purpose]
+	if phase == "" {
+		phase = implied
+	}
+	if phase == "" {
+		if sessionID != "" {
+			return errors.New("session_id requires a phase")
+		}
+		return nil
+	}
+
+	if !containsString(sessionPhases, phase) {
+		return errors.New("invalid phase: " + phase)
+	}
+	if implied != "" && phase != implied {
+		return errors.New("phase does not match the questionnaire purpose: " + purpose)
+	}
+	// Pairing needs the user's identity, which anonymous submissions drop
+	if submission.Anonymous {
+		return errors.New("sessions cannot be used with anonymous questionnaires")
+	}
+	if phase == "post" && sessionID == "" {
+		return errors.New("post-session submissions need the session_id of their pre-session submission")
+	}
+
+	submission.Phase = phase
+	submission.SessionID = sessionID
+	return nil
+}
+
+// checkSessionPhase checks a submission against the session it names, nil
+// when there is none: a pre-session submission opens a new session and a
+// post-session submission completes an open one
+func checkSessionPhase(session *Session, submission *Submission) error {
+	if submission.Phase == "pre" {
+		if session != nil {
+			return errAlreadySubmitted
+		}
+		return nil
+	}
+	if session == nil {
+		return requestError("no pre-session submission for session: " + submission.SessionID)
+	}
+	if session.PostSubmissionID != "" {
+		return errAlreadySubmitted
+	}
+	return nil
+}
+
+// compareSession matches the scores of both submissions by name and their
+// numeric answers by question key, so different pre and post questionnaires
+// can share items
+func compareSession(pre, post *Submission) ([]ScoreChange, []AnswerChange, error) {
+	scores := []ScoreChange{}
+	answers := []AnswerChange{}
+
+	preScores := make(map[string]float64)
+	for _, s := range pre.Scores {
+		preScores[s.Name] = s.Value
+	}
+	for _, s := range post.Scores {
+		if v, ok := preScores[s.Name]; ok {
+			scores = append(scores, ScoreChange{
+				Name:   s.Name,
+				Pre:    v,
+				Post:   s.Value,
+				Change: math.Round((s.Value-v)*100) / 100,
+			})
+		}
+	}
+
+	versions := make(map[string]*QuestionnaireVersion)
+	numericAnswers := func(s *Submission) (map[string]int, []string, error) {
+		questions, err := submissionQuestions(s, versions)
+		if err != nil {
+			return nil, nil, err
+		}
+		given := make(map[string]Answer)
+		for _, a := range s.Answers {
+			given[a.ID] = a
+		}
+		values := make(map[string]int)
+		var keys []string
+		for _, q := range questions {
+			a, ok := given[q.ID]
+			if !ok || (q.Type != "scale" && q.Type != "slider") {
+				continue
+			}
+			v, err := parseAnswerValue(q, a)
+			n, isInt := v.(int)
+			if err != nil || !isInt {
+				continue
+			}
+			key := q.Key
+			if key == "" {
+				key = q.ID
+			}
+			values[key] = n
+			keys = append(keys, key)
+		}
+		return values, keys, nil
+	}
+
+	preValues, _, err := numericAnswers(pre)
+	if err != nil {
+		return nil, nil, err
+	}
+	postValues, keys, err := numericAnswers(post)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, key := range keys {
+		if v, ok := preValues[key]; ok {
+			answers = append(answers, AnswerChange{
+				Key:    key,
+				Pre:    v,
+				Post:   postValues[key],
+				Change: postValues[key] - v,
+			})
+		}
+	}
+	return scores, answers, nil
+}
+
+func handleGetSessions(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	sessions, err := db.GetUserSessions(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch sessions"})
+		return
+	}
+
+	if sessions == nil {
+		sessions = []Session{}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: SessionsResponse{
+			Sessions: sessions,
+		},
+	})
+}
+
+// handleGetSessionResult returns both submissions of a session with the
+// change between them. Until the post-session submission is made only the
+// pre-session one is returned.
+func handleGetSessionResult(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	session, err := db.GetSession(userID.(string), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, errSessionNotFound) {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Session not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch session"})
+		}
+		return
+	}
+
+	result := SessionResult{
+		Session: *session,
+		Scores:  []ScoreChange{},
+		Answers: []AnswerChange{},
+	}
+	if result.Pre, err = db.GetSubmission(session.PreSubmissionID); err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch submissions"})
+		return
+	}
+	if session.PostSubmissionID != "" {
+		if result.Post, err = db.GetSubmission(session.PostSubmissionID); err != nil {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch submissions"})
+			return
+		}
+		if result.Scores, result.Answers, err = compareSession(result.Pre, result.Post); err != nil {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to compare submissions"})
+			return
+		}
+		// Score changes reveal the scores of both phases
+		shown := make(map[string]bool)
+		if !scoresShown(result.Pre, shown) || !scoresShown(result.Post, shown) {
+			result.Scores = []ScoreChange{}
+		}
+		*result.Post = ownSubmissions([]Submission{*result.Post})[0]
+	}
+	*result.Pre = ownSubmissions([]Submission{*result.Pre})[0]
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: result})
+}
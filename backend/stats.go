@@ -0,0 +1,394 @@
+package backend
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func roundStat(x float64) float64 {
+	return math.Round(x*1000) / 1000
+}
+
+func mean(xs []float64) float64 {
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+func median(xs []float64) float64 {
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// variance returns the sample variance
+func variance(xs []float64) float64 {
+	m := mean(xs)
+	sum := 0.0
+	for _, x := range xs {
+		sum += (x - m) * (x - m)
+	}
+	return sum / float64(len(xs)-1)
+}
+
+// pearson returns the correlation of two series, which is undefined when
+// either of them does not vary
+func pearson(xs, ys []float64) (float64, bool) {
+	mx, my := mean(xs), mean(ys)
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+	return sxy / math.Sqrt(sxx*syy), true
+}
+
+// cronbachAlpha computes the internal consistency of items from complete
+// cases, one row of item values per case
+func cronbachAlpha(rows [][]float64) (float64, bool) {
+	k := len(rows[0])
+	totals := make([]float64, len(rows))
+	itemVariances := 0.0
+	for i := 0; i < k; i++ {
+		column := make([]float64, len(rows))
+		for j, row := range rows {
+			column[j] = row[i]
+			totals[j] += row[i]
+		}
+		itemVariances += variance(column)
+	}
+	totalVariance := variance(totals)
+	if totalVariance == 0 {
+		return 0, false
+	}
+	return float64(k) / float64(k-1) * (1 - itemVariances/totalVariance), true
+}
+
+func histogram(q Question, counts map[string]int) []HistogramBin {
+	var values []string
+	switch q.Type {
+	case "scale":
+		for v := q.Min; v <= q.Max; v++ {
+			values = append(values, strconv.Itoa(v))
+		}
+	case "slider":
+		for v := range counts {
+			values = append(values, v)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			a, _ := strconv.Atoi(values[i])
+			b, _ := strconv.Atoi(values[j])
+			return a < b
+		})
+	case "choice", "multi_choice":
+		for _, opt := range q.Options {
+			values = append(values, opt.Value)
+		}
+	case "boolean":
+		values = []string{"true", "false"}
+	default:
+		return nil
+	}
+
+	bins := make([]HistogramBin, len(values))
+	for i, v := range values {
+		bins[i] = HistogramBin{Value: v, Count: counts[v]}
+	}
+	return bins
+}
+
+// questionnaireStats computes item statistics of submissions against the
+// questions of a version. A question only counts as missing for submissions
+// whose own version, looked up in shown, had it and did not hide it by its
+// display condition. Submissions without a known version only count their
+// answers. Items reverse scored by any scoring rule are reversed for the
+// reliability statistics.
+func questionnaireStats(version *QuestionnaireVersion, submissions []Submission, shown map[int][]Question) *QuestionnaireStats {
+	questions := version.Questions
+
+	reversed := make(map[string]bool)
+	for _, rule := range version.Scoring {
+		for _, item := range rule.Items {
+			if item.Reverse {
+				reversed[item.QuestionID] = true
+			}
+		}
+	}
+	var numeric []Question
+	for _, q := range questions {
+		if q.Type == "scale" || q.Type == "slider" {
+			numeric = append(numeric, q)
+		}
+	}
+
+	stats := make([]QuestionStats, len(questions))
+	counts := make([]map[string]int, len(questions))
+	values := make([][]float64, len(questions))
+	for i, q := range questions {
+		stats[i] = QuestionStats{QuestionID: q.ID, Key: q.Key, Question: q.Question, Type: q.Type}
+		counts[i] = make(map[string]int)
+	}
+
+	var rows [][]float64
+	for _, s := range submissions {
+		own, known := shown[s.Version]
+		inVersion := make(map[string]bool)
+		for _, q := range own {
+			inVersion[q.ID] = true
+		}
+		hidden := make(map[string]bool)
+		_, hiddenIDs, _ := validateAnswers(own, s.Answers)
+		for _, id := range hiddenIDs {
+			hidden[id] = true
+		}
+		given := make(map[string]Answer)
+		for _, a := range s.Answers {
+			given[a.ID] = a
+		}
+
+		numericValues := make(map[string]float64)
+		for i, q := range questions {
+			if hidden[q.ID] {
+				continue
+			}
+			var value interface{}
+			if a, ok := given[q.ID]; ok {
+				// Answers that no longer fit an edited question count as missing
+				value, _ = parseAnswerValue(q, a)
+			}
+			if value == nil {
+				if known && inVersion[q.ID] {
+					stats[i].Missing++
+				}
+				continue
+			}
+			stats[i].Responses++
+
+			switch v := value.(type) {
+			case int:
+				counts[i][strconv.Itoa(v)]++
+				values[i] = append(values[i], float64(v))
+				numericValues[q.ID] = float64(v)
+			case bool:
+				counts[i][strconv.FormatBool(v)]++
+			case string:
+				counts[i][v]++
+			case []string:
+				for _, option := range v {
+					counts[i][option]++
+				}
+			}
+		}
+
+		if len(numeric) > 0 && len(numericValues) == len(numeric) {
+			row := make([]float64, len(numeric))
+			for i, q := range numeric {
+				row[i] = numericValues[q.ID]
+				if reversed[q.ID] {
+					row[i] = float64(q.Max+q.Min) - row[i]
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	for i, q := range questions {
+		if total := stats[i].Responses + stats[i].Missing; total > 0 {
+			stats[i].MissingRate = roundStat(float64(stats[i].Missing) / float64(total))
+		}
+		stats[i].Histogram = histogram(q, counts[i])
+		if len(values[i]) > 0 {
+			m, md := roundStat(mean(values[i])), roundStat(median(values[i]))
+			stats[i].Mean, stats[i].Median = &m, &md
+		}
+	}
+
+	reliability := Reliability{Items: len(numeric), Cases: len(rows)}
+	if len(numeric) >= 2 && len(rows) >= 2 {
+		if alpha, ok := cronbachAlpha(rows); ok {
+			alpha = roundStat(alpha)
+			reliability.CronbachAlpha = &alpha
+		}
+
+		// Each item is correlated with the total of the other items, so it
+		// does not inflate its own correlation
+		for n, q := range numeric {
+			item := make([]float64, len(rows))
+			rest := make([]float64, len(rows))
+			for j, row := range rows {
+				item[j] = row[n]
+				for m, v := range row {
+					if m != n {
+						rest[j] += v
+					}
+				}
+			}
+			if r, ok := pearson(item, rest); ok {
+				r = roundStat(r)
+				for i := range stats {
+					if stats[i].QuestionID == q.ID {
+						stats[i].ItemTotalCorrelation = &r
+					}
+				}
+			}
+		}
+	}
+
+	return &QuestionnaireStats{
+		QuestionnaireID: version.QuestionnaireID,
+		Version:         version.Version,
+		Submissions:     len(submissions),
+		Questions:       stats,
+		Reliability:     reliability,
+	}
+}
+
+// parseDateFilter reads a date filter given as a date or an RFC 3339 time. A
+// date used as the end of a range includes the whole day.
+func parseDateFilter(value string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return t, errors.New("invalid date: " + value)
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// handleGetQuestionnaireStats computes item statistics and reliability over
+// the submissions of a questionnaire. Without ?version= all versions are
+// read against the latest one. Submissions can be limited by ?from= and ?to=
+// and to the users of a ?cohort=, which leaves out anonymous submissions.
+// Statistics over fewer than -min-group-size submissions are suppressed, so
+// they cannot single out the answers of a few people.
+func handleGetQuestionnaireStats(c *gin.Context) {
+	id := c.Param("id")
+
+	var version *QuestionnaireVersion
+	var err error
+	number := 0
+	if v := c.Query("version"); v != "" {
+		if number, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Invalid version"})
+			return
+		}
+		version, err = db.GetQuestionnaireVersion(id, number)
+	} else {
+		version, err = db.GetPublishedQuestionnaire(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Questionnaire version not found"})
+		return
+	}
+
+	var from, to time.Time
+	if v := c.Query("from"); v != "" {
+		if from, err = parseDateFilter(v, false); err != nil {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = parseDateFilter(v, true); err != nil {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+			return
+		}
+	}
+
+	cohort := strings.TrimSpace(c.Query("cohort"))
+	members := make(map[string]bool)
+	if cohort != "" {
+		users, err := db.GetAllUsers()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch users"})
+			return
+		}
+		for _, u := range users {
+			if u.Cohort == cohort {
+				members[u.ID] = true
+			}
+		}
+	}
+
+	versions, err := db.GetQuestionnaireVersions(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch versions"})
+		return
+	}
+	shown := make(map[int][]Question)
+	for _, v := range versions {
+		shown[v.Version] = v.Questions
+	}
+
+	submissions, err := db.GetAllSubmissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch submissions"})
+		return
+	}
+
+	var selected []Submission
+	for _, s := range submissions {
+		switch {
+		case s.QuestionnaireID != id:
+		case number > 0 && s.Version != number:
+		case !from.IsZero() && s.CreatedAt.Before(from):
+		case !to.IsZero() && !s.CreatedAt.Before(to):
+		case cohort != "" && !members[s.UserID]:
+		default:
+			selected = append(selected, s)
+		}
+	}
+
+	if len(selected) < *minGroupSize {
+		c.JSON(http.StatusOK, GenericResponse{
+			Success: true,
+			Data: QuestionnaireStats{
+				QuestionnaireID: version.QuestionnaireID,
+				Version:         version.Version,
+				Questions:       []QuestionStats{},
+				Suppressed:      true,
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: questionnaireStats(version, selected, shown)})
+}
+
+func handleSetUserCohort(c *gin.Context) {
+	var req CohortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	user, err := db.SetUserCohort(c.Param("id"), strings.TrimSpace(req.Cohort))
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: user})
+}
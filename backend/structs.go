@@ -1,6 +1,9 @@
 package backend
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // GenericResponse represents a generic JSON response
 type GenericResponse struct {
@@ -9,36 +12,303 @@ type GenericResponse struct {
 }
 
 type ProfileResponse struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Picture string `json:"picture"`
-	IsAdmin bool   `json:"is_admin"`
-	Created string `json:"created"`
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Picture  string   `json:"picture"`
+	IsAdmin  bool     `json:"is_admin"`
+	Roles    []string `json:"roles"`
+	Language string   `json:"language"`
+	Created  string   `json:"created"`
 }
 
+// Answer is the answer to a single question. Value holds the typed answer;
+// Question carries the same answer as a string for older clients.
 type Answer struct {
-	ID       string `json:"id"`
-	Question string `json:"question"`
+	ID       string          `json:"id"`
+	Question string          `json:"question"`
+	Value    json.RawMessage `json:"value,omitempty"`
 }
 
 type AnswersRequest struct {
-	Answers []Answer `json:"answers"`
+	QuestionnaireID string   `json:"questionnaire_id"`
+	ScheduleID      string   `json:"schedule_id"`
+	Phase           string   `json:"phase"`
+	SessionID       string   `json:"session_id"`
+	Answers         []Answer `json:"answers"`
 }
 
-var questionTypes = []string{"scale"}
+var questionTypes = []string{"scale", "choice", "multi_choice", "text", "boolean", "slider"}
+
+// QuestionOption is a selectable answer of a choice or multi_choice question
+type QuestionOption struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
 
 type Question struct {
-	ID       string `json:"id"`
-	Question string `json:"question"`
-	Type     string `json:"type"`
-	Min      int    `json:"min,omitempty"`
-	Max      int    `json:"max,omitempty"`
+	ID        string           `json:"id"`
+	Key       string           `json:"key,omitempty"`
+	Question  string           `json:"question"`
+	Type      string           `json:"type"`
+	Min       int              `json:"min"`
+	Max       int              `json:"max"`
+	Step      int              `json:"step,omitempty"`
+	Options   []QuestionOption `json:"options,omitempty"`
+	MaxLength int              `json:"max_length,omitempty"`
+	Required  bool             `json:"required,omitempty"`
+	Position  int              `json:"position"`
+	Condition *Condition       `json:"condition,omitempty"`
+	MinLabel  string           `json:"min_label,omitempty"`
+	MaxLabel  string           `json:"max_label,omitempty"`
+
+	Translations map[string]QuestionTranslation `json:"translations,omitempty"`
+
+	Archived   bool       `json:"archived,omitempty"`
+	ArchivedBy string     `json:"archived_by,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+// QuestionTranslation holds the translated texts of a question for one
+// locale. Options maps option values to translated labels.
+type QuestionTranslation struct {
+	Question string            `json:"question,omitempty"`
+	Options  map[string]string `json:"options,omitempty"`
+	MinLabel string            `json:"min_label,omitempty"`
+	MaxLabel string            `json:"max_label,omitempty"`
+}
+
+// QuestionnaireTranslation holds the translated title and description of a
+// questionnaire for one locale
+type QuestionnaireTranslation struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// MissingTranslation lists the untranslated fields of a question or, when
+// QuestionID is empty, of the questionnaire itself
+type MissingTranslation struct {
+	QuestionID string   `json:"question_id,omitempty"`
+	Locale     string   `json:"locale"`
+	Fields     []string `json:"fields"`
+}
+
+// MissingTranslationsResponse represents a list of missing translations
+type MissingTranslationsResponse struct {
+	DefaultLocale string               `json:"default_locale"`
+	Locales       []string             `json:"locales"`
+	Missing       []MissingTranslation `json:"missing"`
+}
+
+// Condition decides whether a question is shown. It is either a comparison
+// on the answer to an earlier question or a combination of nested conditions.
+type Condition struct {
+	All        []Condition     `json:"all,omitempty"`
+	Any        []Condition     `json:"any,omitempty"`
+	QuestionID string          `json:"question_id,omitempty"`
+	Operator   string          `json:"operator,omitempty"`
+	Value      json.RawMessage `json:"value,omitempty"`
+}
+
+// ReorderRequest lists question IDs in their new order
+type ReorderRequest struct {
+	QuestionIDs []string `json:"question_ids" binding:"required"`
 }
 
 type QuestionsResponse struct {
 	Questions []Question `json:"questions"`
+	Locale    string     `json:"locale,omitempty"`
+}
+
+var questionnairePurposes = []string{"pre_session", "post_session", "pulse", "other"}
+
+// Questionnaire groups an ordered list of questions into a named form
+type Questionnaire struct {
+	ID          string    `json:"id"`
+	Key         string    `json:"key,omitempty"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Purpose     string    `json:"purpose"`
+	QuestionIDs []string  `json:"question_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Anonymous submissions are stored without a link to the user
+	Anonymous bool `json:"anonymous"`
+
+	// Scoring computes scores from the answers of each submission
+	Scoring []ScoringRule `json:"scoring,omitempty"`
+
+	// ShowScores returns scores to the users who submitted. Otherwise only
+	// admins and analysts see them.
+	ShowScores bool `json:"show_scores,omitempty"`
+
+	// Throttle limits how often users are prompted to answer. It takes effect
+	// without publishing.
+	Throttle *ThrottleRule `json:"throttle,omitempty"`
+
+	Translations map[string]QuestionnaireTranslation `json:"translations,omitempty"`
+
+	// PublishedVersion is the latest published version, 0 while unpublished
+	PublishedVersion int `json:"published_version"`
+}
+
+var scoringMethods = []string{"sum", "mean", "weighted"}
+
+// ScoringRule computes one score of a questionnaire from the answers to its
+// items, or from earlier scores for derived scales. The result is optionally
+// multiplied and placed in a band.
+type ScoringRule struct {
+	Name       string        `json:"name"`
+	Method     string        `json:"method,omitempty"`
+	Items      []ScoringItem `json:"items"`
+	Multiplier float64       `json:"multiplier,omitempty"`
+	Bands      []ScoreBand   `json:"bands,omitempty"`
+}
+
+// ScoringItem is a question or an earlier score counted by a scoring rule.
+// Reverse scored questions count max + min - answer.
+type ScoringItem struct {
+	QuestionID string  `json:"question_id,omitempty"`
+	Score      string  `json:"score,omitempty"`
+	Reverse    bool    `json:"reverse,omitempty"`
+	Weight     float64 `json:"weight,omitempty"`
+}
+
+// ScoringRequest replaces the scoring rules of a questionnaire
+type ScoringRequest struct {
+	Scoring []ScoringRule `json:"scoring"`
+}
+
+// RescoreResponse reports how many submissions were rescored, and how many
+// were skipped because no rule applies to the questions of their version
+type RescoreResponse struct {
+	QuestionnaireID string `json:"questionnaire_id"`
+	Version         int    `json:"version"`
+	Rescored        int    `json:"rescored"`
+	Skipped         int    `json:"skipped"`
+}
+
+// ScoreBand labels the scores from Min up to the Min of the next band
+type ScoreBand struct {
+	Min   float64 `json:"min"`
+	Label string  `json:"label"`
+}
+
+// Score is the result of a scoring rule for a submission
+type Score struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Band  string  `json:"band,omitempty"`
+}
+
+// Instrument is a validated questionnaire from the built-in library. Its
+// questions and scoring refer to each other by the IDs given here, which are
+// replaced when the instrument is instantiated.
+type Instrument struct {
+	Key         string        `json:"key"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Purpose     string        `json:"purpose"`
+	Reference   string        `json:"reference"`
+	Questions   []Question    `json:"questions"`
+	Scoring     []ScoringRule `json:"scoring"`
+}
+
+// InstrumentsResponse represents the instrument library
+type InstrumentsResponse struct {
+	Instruments []Instrument `json:"instruments"`
+}
+
+// QuestionnaireVersion is an immutable snapshot of a questionnaire and its
+// questions, taken when the questionnaire is published
+type QuestionnaireVersion struct {
+	QuestionnaireID string     `json:"questionnaire_id"`
+	Version         int        `json:"version"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	Purpose         string     `json:"purpose"`
+	Questions       []Question `json:"questions,omitempty"`
+	Anonymous       bool       `json:"anonymous"`
+	PublishedAt     time.Time  `json:"published_at"`
+	PublishedBy     string     `json:"published_by"`
+
+	Translations map[string]QuestionnaireTranslation `json:"translations,omitempty"`
+	Scoring      []ScoringRule                       `json:"scoring,omitempty"`
+	ShowScores   bool                                `json:"show_scores,omitempty"`
+}
+
+// QuestionnaireVersionsResponse represents a list of questionnaire versions
+type QuestionnaireVersionsResponse struct {
+	Versions []QuestionnaireVersion `json:"versions"`
 }
 
+// FieldChange is a single field that differs between two versions
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// QuestionChange lists the changed fields of a question present in both versions
+type QuestionChange struct {
+	QuestionID string        `json:"question_id"`
+	Changes    []FieldChange `json:"changes"`
+}
+
+// QuestionnaireDiff describes what changed between two questionnaire versions
+type QuestionnaireDiff struct {
+	QuestionnaireID string           `json:"questionnaire_id"`
+	From            int              `json:"from"`
+	To              int              `json:"to"`
+	Changes         []FieldChange    `json:"changes"`
+	Added           []Question       `json:"added"`
+	Removed         []Question       `json:"removed"`
+	Changed         []QuestionChange `json:"changed"`
+	Reordered       bool             `json:"reordered"`
+}
+
+// QuestionnairesResponse represents a list of questionnaires
+type QuestionnairesResponse struct {
+	Questionnaires []Questionnaire `json:"questionnaires"`
+}
+
+// QuestionnaireDocument is the portable form of a questionnaire used for
+// import and export. Questions are identified by their stable key, and
+// conditions and scoring items refer to questions by key.
+type QuestionnaireDocument struct {
+	Key          string                              `json:"key"`
+	Title        string                              `json:"title"`
+	Description  string                              `json:"description,omitempty"`
+	Purpose      string                              `json:"purpose"`
+	Anonymous    bool                                `json:"anonymous,omitempty"`
+	Translations map[string]QuestionnaireTranslation `json:"translations,omitempty"`
+	Scoring      []ScoringRule                       `json:"scoring,omitempty"`
+	ShowScores   bool                                `json:"show_scores,omitempty"`
+	Questions    []Question                          `json:"questions"`
+}
+
+// ImportResult reports what an import changed. Questions are listed by key.
+type ImportResult struct {
+	QuestionnaireID string   `json:"questionnaire_id"`
+	Questionnaire   string   `json:"questionnaire"`
+	Created         []string `json:"created"`
+	Updated         []string `json:"updated"`
+	Unchanged       []string `json:"unchanged"`
+}
+
+// QuestionnaireDetailResponse represents a questionnaire with its questions
+type QuestionnaireDetailResponse struct {
+	Questionnaire
+	Questions []Question `json:"questions"`
+}
+
+const (
+	roleAnalyst      = "analyst"
+	roleReidentifier = "reidentifier"
+)
+
+var userRoles = []string{roleAnalyst, roleReidentifier}
+
 // User represents a user in the system
 type User struct {
 	ID       string    `json:"id"`
@@ -47,9 +317,22 @@ type User struct {
 	Name     string    `json:"name"`
 	Picture  string    `json:"picture"`
 	IsAdmin  bool      `json:"is_admin"`
+	Roles    []string  `json:"roles,omitempty"`
+	Language string    `json:"language,omitempty"`
+	Cohort   string    `json:"cohort,omitempty"`
 	Created  time.Time `json:"created"`
 }
 
+// RolesRequest replaces the roles of a user
+type RolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+// CohortRequest assigns a user to a cohort for analytics
+type CohortRequest struct {
+	Cohort string `json:"cohort"`
+}
+
 // LoginRequest represents the login form data
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -63,17 +346,123 @@ type RegisterRequest struct {
 	Name     string `json:"name" binding:"required"`
 }
 
+// ValidationError describes a single invalid field of a request
+type ValidationError struct {
+	Field      string `json:"field"`
+	QuestionID string `json:"question_id,omitempty"`
+	Message    string `json:"message"`
+}
+
+// ValidationErrorsResponse is returned when a request has invalid fields
+type ValidationErrorsResponse struct {
+	Message string            `json:"message"`
+	Errors  []ValidationError `json:"errors"`
+}
+
 // Submission represents a completed questionnaire
 type Submission struct {
-	ID        string    `json:"id"`
-	UserID    string    `json:"user_id"`
+	ID              string               `json:"id"`
+	UserID          string               `json:"user_id"`
+	QuestionnaireID string               `json:"questionnaire_id,omitempty"`
+	Version         int                  `json:"questionnaire_version,omitempty"`
+	Anonymous       bool                 `json:"anonymous,omitempty"`
+	ScheduleID      string               `json:"schedule_id,omitempty"`
+	WindowStart     *time.Time           `json:"window_start,omitempty"`
+	Phase           string               `json:"phase,omitempty"`
+	SessionID       string               `json:"session_id,omitempty"`
+	Answers         []Answer             `json:"answers"`
+	Scores          []Score              `json:"scores,omitempty"`
+	CreatedAt       time.Time            `json:"created_at"`
+	AmendedAt       *time.Time           `json:"amended_at,omitempty"`
+	Revisions       []SubmissionRevision `json:"revisions,omitempty"`
+}
+
+// SubmissionRevision keeps answers that an amendment replaced, together with
+// who amended them, when and why
+type SubmissionRevision struct {
 	Answers   []Answer  `json:"answers"`
-	CreatedAt time.Time `json:"created_at"`
+	Scores    []Score   `json:"scores,omitempty"`
+	AmendedAt time.Time `json:"amended_at"`
+	AmendedBy string    `json:"amended_by,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// AmendRequest represents corrected answers to a submission. Admins must give
+// a reason.
+type AmendRequest struct {
+	Answers []Answer `json:"answers"`
+	Reason  string   `json:"reason"`
+}
+
+// Draft is a partially answered questionnaire saved for later. A user has at
+// most one draft per questionnaire.
+type Draft struct {
+	UserID          string    `json:"user_id"`
+	QuestionnaireID string    `json:"questionnaire_id"`
+	Version         int       `json:"questionnaire_version"`
+	Phase           string    `json:"phase,omitempty"`
+	SessionID       string    `json:"session_id,omitempty"`
+	Answers         []Answer  `json:"answers"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// DraftRequest represents the answers given so far
+type DraftRequest struct {
+	Phase     string   `json:"phase"`
+	SessionID string   `json:"session_id"`
+	Answers   []Answer `json:"answers"`
+}
+
+// DraftsResponse represents a list of drafts
+type DraftsResponse struct {
+	Drafts []Draft `json:"drafts"`
+}
+
+var scheduleFrequencies = []string{"daily", "weekly"}
+
+// Schedule opens a questionnaire to every user on a recurrence. Each
+// occurrence opens a window of WindowHours in which a user may submit once.
+type Schedule struct {
+	ID              string     `json:"id"`
+	QuestionnaireID string     `json:"questionnaire_id"`
+	Frequency       string     `json:"frequency"`
+	Weekdays        []string   `json:"weekdays,omitempty"`
+	Time            string     `json:"time"`
+	Timezone        string     `json:"timezone"`
+	WindowHours     int        `json:"window_hours"`
+	StartsAt        *time.Time `json:"starts_at,omitempty"`
+	EndsAt          *time.Time `json:"ends_at,omitempty"`
+	Paused          bool       `json:"paused"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// SchedulesResponse represents a list of schedules
+type SchedulesResponse struct {
+	Schedules []Schedule `json:"schedules"`
+}
+
+// PendingSurvey is a scheduled questionnaire the user has yet to answer in
+// its current window
+type PendingSurvey struct {
+	ScheduleID      string    `json:"schedule_id"`
+	QuestionnaireID string    `json:"questionnaire_id"`
+	Title           string    `json:"title"`
+	WindowStart     time.Time `json:"window_start"`
+	WindowEnd       time.Time `json:"window_end"`
+}
+
+// PendingSurveysResponse represents a list of pending surveys
+type PendingSurveysResponse struct {
+	Surveys []PendingSurvey `json:"surveys"`
 }
 
 // SubmissionResponse represents the response for a questionnaire submission
 type SubmissionResponse struct {
 	ID        string    `json:"id"`
+	SessionID string    `json:"session_id,omitempty"`
+	Scores    []Score   `json:"scores,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -86,3 +475,391 @@ type UserSubmissionsResponse struct {
 type UsersResponse struct {
 	Users []User `json:"users"`
 }
+
+// Study is a pseudonymization scope. Every study has its own HMAC key, so the
+// same user gets unrelated pseudonyms in different studies.
+type Study struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name" binding:"required"`
+	Description  string    `json:"description"`
+	CreatedAt    time.Time `json:"created_at"`
+	KeyRotatedAt time.Time `json:"key_rotated_at"`
+}
+
+// StudiesResponse represents a list of studies
+type StudiesResponse struct {
+	Studies []Study `json:"studies"`
+}
+
+// PseudonymousSubmission is a submission as seen by analysts. The outer
+// UserID shadows the embedded one and is always left empty.
+type PseudonymousSubmission struct {
+	Submission
+	UserID    string `json:"user_id,omitempty"`
+	Pseudonym string `json:"pseudonym,omitempty"`
+}
+
+// PseudonymousSubmissionsResponse represents a list of pseudonymized submissions
+type PseudonymousSubmissionsResponse struct {
+	StudyID     string                   `json:"study_id"`
+	Submissions []PseudonymousSubmission `json:"submissions"`
+}
+
+// ReidentifyRequest asks which user is behind a pseudonym in a study
+type ReidentifyRequest struct {
+	StudyID   string `json:"study_id" binding:"required"`
+	Pseudonym string `json:"pseudonym" binding:"required"`
+}
+
+// ReidentifyResponse represents the user behind a pseudonym
+type ReidentifyResponse struct {
+	UserID string `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+}
+
+// FHIR R4 resources, limited to the elements tiramisu maps to and from
+
+// FHIRQuestionnaire represents a FHIR Questionnaire resource
+type FHIRQuestionnaire struct {
+	ResourceType string                  `json:"resourceType"`
+	ID           string                  `json:"id,omitempty"`
+	Extension    []FHIRExtension         `json:"extension,omitempty"`
+	Identifier   []FHIRIdentifier        `json:"identifier,omitempty"`
+	Version      string                  `json:"version,omitempty"`
+	Title        string                  `json:"title,omitempty"`
+	Status       string                  `json:"status"`
+	Date         string                  `json:"date,omitempty"`
+	Description  string                  `json:"description,omitempty"`
+	Item         []FHIRQuestionnaireItem `json:"item,omitempty"`
+}
+
+// FHIRQuestionnaireItem represents a question, group or display item
+type FHIRQuestionnaireItem struct {
+	Extension      []FHIRExtension         `json:"extension,omitempty"`
+	LinkID         string                  `json:"linkId"`
+	Text           string                  `json:"text,omitempty"`
+	Type           string                  `json:"type"`
+	EnableWhen     []FHIREnableWhen        `json:"enableWhen,omitempty"`
+	EnableBehavior string                  `json:"enableBehavior,omitempty"`
+	Required       bool                    `json:"required,omitempty"`
+	Repeats        bool                    `json:"repeats,omitempty"`
+	MaxLength      int                     `json:"maxLength,omitempty"`
+	AnswerOption   []FHIRAnswerOption      `json:"answerOption,omitempty"`
+	Item           []FHIRQuestionnaireItem `json:"item,omitempty"`
+}
+
+// FHIRExtension represents an extension with one of the value types used here
+type FHIRExtension struct {
+	URL                  string               `json:"url"`
+	ValueInteger         *int                 `json:"valueInteger,omitempty"`
+	ValueString          string               `json:"valueString,omitempty"`
+	ValueCodeableConcept *FHIRCodeableConcept `json:"valueCodeableConcept,omitempty"`
+}
+
+// FHIRCodeableConcept represents a FHIR CodeableConcept
+type FHIRCodeableConcept struct {
+	Coding []FHIRCoding `json:"coding"`
+}
+
+// FHIRCoding represents a FHIR Coding
+type FHIRCoding struct {
+	System  string `json:"system,omitempty"`
+	Code    string `json:"code"`
+	Display string `json:"display,omitempty"`
+}
+
+// FHIRIdentifier represents a FHIR Identifier
+type FHIRIdentifier struct {
+	System string `json:"system"`
+	Value  string `json:"value"`
+}
+
+// FHIRReference represents a FHIR Reference
+type FHIRReference struct {
+	Reference  string          `json:"reference,omitempty"`
+	Identifier *FHIRIdentifier `json:"identifier,omitempty"`
+}
+
+// FHIREnableWhen represents a condition on the answer to another item
+type FHIREnableWhen struct {
+	Question      string      `json:"question"`
+	Operator      string      `json:"operator"`
+	AnswerBoolean *bool       `json:"answerBoolean,omitempty"`
+	AnswerInteger *int        `json:"answerInteger,omitempty"`
+	AnswerString  *string     `json:"answerString,omitempty"`
+	AnswerCoding  *FHIRCoding `json:"answerCoding,omitempty"`
+}
+
+// FHIRAnswerOption represents a permitted answer of a choice item
+type FHIRAnswerOption struct {
+	ValueCoding  *FHIRCoding `json:"valueCoding,omitempty"`
+	ValueString  *string     `json:"valueString,omitempty"`
+	ValueInteger *int        `json:"valueInteger,omitempty"`
+}
+
+// FHIRQuestionnaireResponse represents a FHIR QuestionnaireResponse resource
+type FHIRQuestionnaireResponse struct {
+	ResourceType  string             `json:"resourceType"`
+	ID            string             `json:"id"`
+	Questionnaire string             `json:"questionnaire,omitempty"`
+	Status        string             `json:"status"`
+	Subject       *FHIRReference     `json:"subject,omitempty"`
+	Authored      string             `json:"authored"`
+	Item          []FHIRResponseItem `json:"item,omitempty"`
+}
+
+// FHIRResponseItem represents the answers to one item
+type FHIRResponseItem struct {
+	LinkID string       `json:"linkId"`
+	Text   string       `json:"text,omitempty"`
+	Answer []FHIRAnswer `json:"answer,omitempty"`
+}
+
+// FHIRAnswer represents a single answer value
+type FHIRAnswer struct {
+	ValueBoolean *bool       `json:"valueBoolean,omitempty"`
+	ValueInteger *int        `json:"valueInteger,omitempty"`
+	ValueString  *string     `json:"valueString,omitempty"`
+	ValueCoding  *FHIRCoding `json:"valueCoding,omitempty"`
+}
+
+// FHIRBundle represents a searchset Bundle
+type FHIRBundle struct {
+	ResourceType string            `json:"resourceType"`
+	Type         string            `json:"type"`
+	Total        int               `json:"total"`
+	Entry        []FHIRBundleEntry `json:"entry"`
+}
+
+// FHIRBundleEntry represents a resource in a Bundle
+type FHIRBundleEntry struct {
+	Resource interface{} `json:"resource"`
+}
+
+// FHIROperationOutcome reports an error to FHIR clients
+type FHIROperationOutcome struct {
+	ResourceType string      `json:"resourceType"`
+	Issue        []FHIRIssue `json:"issue"`
+}
+
+// FHIRIssue represents an issue of an OperationOutcome
+type FHIRIssue struct {
+	Severity    string `json:"severity"`
+	Code        string `json:"code"`
+	Diagnostics string `json:"diagnostics"`
+}
+
+var sessionPhases = []string{"pre", "post"}
+
+// Session pairs the pre-session and post-session submissions of a user
+type Session struct {
+	ID               string     `json:"id"`
+	UserID           string     `json:"user_id"`
+	PreSubmissionID  string     `json:"pre_submission_id"`
+	PostSubmissionID string     `json:"post_submission_id,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	CompletedAt      *time.Time `json:"completed_at,omitempty"`
+}
+
+// SessionsResponse represents a list of sessions
+type SessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+// ScoreChange compares a score between the two phases of a session
+type ScoreChange struct {
+	Name   string  `json:"name"`
+	Pre    float64 `json:"pre"`
+	Post   float64 `json:"post"`
+	Change float64 `json:"change"`
+}
+
+// AnswerChange compares the numeric answers to a question asked in both
+// phases of a session
+type AnswerChange struct {
+	Key    string `json:"key"`
+	Pre    int    `json:"pre"`
+	Post   int    `json:"post"`
+	Change int    `json:"change"`
+}
+
+// SessionResult represents both submissions of a session and how they differ
+type SessionResult struct {
+	Session Session        `json:"session"`
+	Pre     *Submission    `json:"pre"`
+	Post    *Submission    `json:"post"`
+	Scores  []ScoreChange  `json:"scores"`
+	Answers []AnswerChange `json:"answers"`
+}
+
+// IdempotencyRecord is the stored outcome of a request made with an
+// Idempotency-Key. Status is zero while the request is still in progress.
+type IdempotencyRecord struct {
+	RequestHash string    `json:"request_hash"`
+	Status      int       `json:"status,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        []byte    `json:"body,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// DryRunRequest represents answers to check without submitting them
+type DryRunRequest struct {
+	UserID     string   `json:"user_id"`
+	ScheduleID string   `json:"schedule_id"`
+	Phase      string   `json:"phase"`
+	SessionID  string   `json:"session_id"`
+	Answers    []Answer `json:"answers"`
+}
+
+// DryRunResponse reports what submitting the answers would have done
+type DryRunResponse struct {
+	Valid   bool              `json:"valid"`
+	Errors  []ValidationError `json:"errors"`
+	Answers []Answer          `json:"answers"`
+	Hidden  []string          `json:"hidden"`
+	Scores  []Score           `json:"scores"`
+}
+
+// HistogramBin counts the responses with one value
+type HistogramBin struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// QuestionStats describes the responses to one question. Missing counts the
+// submissions that were shown the question but did not answer it.
+type QuestionStats struct {
+	QuestionID           string         `json:"question_id"`
+	Key                  string         `json:"key,omitempty"`
+	Question             string         `json:"question"`
+	Type                 string         `json:"type"`
+	Responses            int            `json:"responses"`
+	Missing              int            `json:"missing"`
+	MissingRate          float64        `json:"missing_rate"`
+	Histogram            []HistogramBin `json:"histogram,omitempty"`
+	Mean                 *float64       `json:"mean,omitempty"`
+	Median               *float64       `json:"median,omitempty"`
+	ItemTotalCorrelation *float64       `json:"item_total_correlation,omitempty"`
+}
+
+// Reliability describes the internal consistency of the scale and slider
+// questions of a questionnaire over submissions that answered all of them
+type Reliability struct {
+	Items         int      `json:"items"`
+	Cases         int      `json:"cases"`
+	CronbachAlpha *float64 `json:"cronbach_alpha,omitempty"`
+}
+
+// QuestionnaireStats represents item-level statistics of a questionnaire.
+// Suppressed statistics carry no figures because too few submissions matched.
+type QuestionnaireStats struct {
+	QuestionnaireID string          `json:"questionnaire_id"`
+	Version         int             `json:"version"`
+	Submissions     int             `json:"submissions"`
+	Questions       []QuestionStats `json:"questions"`
+	Reliability     Reliability     `json:"reliability"`
+	Suppressed      bool            `json:"suppressed,omitempty"`
+}
+
+// ThrottleRule limits prompts for a questionnaire per user. Zero values do not
+// limit; a nil probability prompts every eligible user.
+type ThrottleRule struct {
+	MaxPerWeek    int      `json:"max_per_week,omitempty"`
+	CooldownHours int      `json:"cooldown_hours,omitempty"`
+	Probability   *float64 `json:"probability,omitempty"`
+}
+
+// ThrottleRequest replaces the throttle rule of a questionnaire, or removes it
+// when null
+type ThrottleRequest struct {
+	Throttle *ThrottleRule `json:"throttle"`
+}
+
+// PromptDecision records whether a user was to be prompted for a
+// questionnaire and why: allowed, weekly_limit, cooldown or sampled_out. For
+// anonymous questionnaires UserID holds a participant token instead and
+// DecidedAt only the day.
+type PromptDecision struct {
+	ID              string    `json:"id"`
+	QuestionnaireID string    `json:"questionnaire_id"`
+	UserID          string    `json:"user_id"`
+	Present         bool      `json:"present"`
+	Reason          string    `json:"reason"`
+	DecidedAt       time.Time `json:"decided_at"`
+}
+
+// PromptDecisionsResponse represents a list of prompt decisions
+type PromptDecisionsResponse struct {
+	Decisions []PromptDecision `json:"decisions"`
+}
+
+// Room is a relaxation room employees can use. Inactive rooms are only
+// listed to admins.
+type Room struct {
+	ID                 string    `json:"id"`
+	Name               string    `json:"name"`
+	Location           string    `json:"location"`
+	Capacity           int       `json:"capacity"`
+	Amenities          []string  `json:"amenities"`
+	Photos             []string  `json:"photos"`
+	AccessibilityNotes string    `json:"accessibility_notes,omitempty"`
+	Active             bool      `json:"active"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// RoomRequest represents a room update. A room stays active or inactive
+// when active is left out.
+type RoomRequest struct {
+	Room
+	Active *bool `json:"active"`
+}
+
+// RoomsResponse represents a list of rooms
+type RoomsResponse struct {
+	Rooms []Room `json:"rooms"`
+}
+
+// Booking reserves seats in a room for a time slot. Cancelled bookings are
+// kept and no longer count against the room's capacity.
+type Booking struct {
+	ID          string     `json:"id"`
+	RoomID      string     `json:"room_id"`
+	UserID      string     `json:"user_id"`
+	Start       time.Time  `json:"start"`
+	End         time.Time  `json:"end"`
+	Seats       int        `json:"seats"`
+	Note        string     `json:"note,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	CancelledBy string     `json:"cancelled_by,omitempty"`
+}
+
+// BookingRequest books a room; seats defaults to 1
+type BookingRequest struct {
+	RoomID string    `json:"room_id" binding:"required"`
+	Start  time.Time `json:"start" binding:"required"`
+	End    time.Time `json:"end" binding:"required"`
+	Seats  int       `json:"seats"`
+	Note   string    `json:"note"`
+}
+
+// BookingsResponse represents a list of bookings
+type BookingsResponse struct {
+	Bookings []Booking `json:"bookings"`
+}
+
+// RoomCalendar lists the bookings of a room in a calendar
+type RoomCalendar struct {
+	Room     Room      `json:"room"`
+	Bookings []Booking `json:"bookings"`
+}
+
+// CalendarResponse represents the bookings of every room over a time range
+type CalendarResponse struct {
+	From  time.Time      `json:"from"`
+	To    time.Time      `json:"to"`
+	Rooms []RoomCalendar `json:"rooms"`
+}
@@ -0,0 +1,187 @@
+package backend
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pseudonymize derives the stable pseudonym of a user within a study
+func pseudonymize(key []byte, userID string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(userID))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// roleMiddleware checks the user's current roles, so a role that is taken
+// away stops working before the user's token expires
+func roleMiddleware(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := c.Get("userID")
+		user, err := db.GetUser(userID.(string))
+		if err != nil || !containsString(user.Roles, role) {
+			c.JSON(http.StatusForbidden, GenericResponse{Success: false, Data: "Role " + role + " required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+func handleSetUserRoles(c *gin.Context) {
+	var req RolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	for _, role := range req.Roles {
+		if !containsString(userRoles, role) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Invalid role: " + role})
+			return
+		}
+	}
+
+	user, err := db.SetUserRoles(c.Param("id"), req.Roles)
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: user})
+}
+
+func handleCreateStudy(c *gin.Context) {
+	var study Study
+	if err := c.ShouldBindJSON(&study); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	study.ID = ""
+	if err := db.CreateStudy(&study); err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to create study"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: study})
+}
+
+func handleGetStudies(c *gin.Context) {
+	studies, err := db.GetStudies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch studies"})
+		return
+	}
+
+	if studies == nil {
+		studies = []Study{}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: StudiesResponse{
+			Studies: studies,
+		},
+	})
+}
+
+func handleRotateStudyKey(c *gin.Context) {
+	study, err := db.RotateStudyKey(c.Param("id"))
+	if err != nil {
+		if err.Error() == "study not found" {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to rotate study key"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: study})
+}
+
+func handleGetPseudonymousSubmissions(c *gin.Context) {
+	studyID := c.Query("study")
+	if studyID == "" {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "study query parameter is required"})
+		return
+	}
+
+	key, err := db.GetStudyKey(studyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Study not found"})
+		return
+	}
+
+	submissions, err := db.GetAllSubmissions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch submissions"})
+		return
+	}
+
+	result := make([]PseudonymousSubmission, 0, len(submissions))
+	for _, s := range submissions {
+		// Amendments by the user themselves would identify them
+		if len(s.Revisions) > 0 {
+			revisions := make([]SubmissionRevision, len(s.Revisions))
+			for i, r := range s.Revisions {
+				r.AmendedBy = ""
+				revisions[i] = r
+			}
+			s.Revisions = revisions
+		}
+		p := PseudonymousSubmission{Submission: s}
+		// Anonymous submissions have no user to derive a pseudonym from
+		if s.UserID != "" {
+			p.Pseudonym = pseudonymize(key, s.UserID)
+		}
+		result = append(result, p)
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: PseudonymousSubmissionsResponse{
+			StudyID:     studyID,
+			Submissions: result,
+		},
+	})
+}
+
+func handleReidentify(c *gin.Context) {
+	var req ReidentifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	key, err := db.GetStudyKey(req.StudyID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Study not found"})
+		return
+	}
+
+	users, err := db.GetAllUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch users"})
+		return
+	}
+
+	for _, u := range users {
+		if hmac.Equal([]byte(pseudonymize(key, u.ID)), []byte(req.Pseudonym)) {
+			c.JSON(http.StatusOK, GenericResponse{
+				Success: true,
+				Data: ReidentifyResponse{
+					UserID: u.ID,
+					Name:   u.Name,
+					Email:  u.Email,
+				},
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "No user matches this pseudonym"})
+}
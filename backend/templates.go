@@ -0,0 +1,127 @@
+package backend
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTemplateValueLength limits the context values put into question texts
+const maxTemplateValueLength = 100
+
+// templateVariables are the variables question texts may use as {name} or
+// {name|default}
+var templateVariables = []string{"first_name", "room", "session_type", "session_duration"}
+
+// templatePattern matches a variable or an escaped brace, {{ or }}
+var templatePattern = regexp.MustCompile(`\{\{|\}\}|\{([^{}]*)\}`)
+
+func isBraceEscape(match string) bool {
+	return match == "{{" || match == "}}"
+}
+
+// validateTemplate checks that a text only uses known template variables
+func validateTemplate(text string) error {
+	if strings.ContainsAny(templatePattern.ReplaceAllString(text, ""), "{}") {
+		return errors.New("unbalanced braces in question text, use {variable}, {variable|default}, or {{ and }} for literal braces")
+	}
+	for _, m := range templatePattern.FindAllStringSubmatch(text, -1) {
+		if isBraceEscape(m[0]) {
+			continue
+		}
+		name, _, _ := strings.Cut(m[1], "|")
+		name = strings.TrimSpace(name)
+		if !containsString(templateVariables, name) {
+			return errors.New("unknown template variable: {" + name + "} (use " + strings.Join(templateVariables, ", ") + ")")
+		}
+	}
+	return nil
+}
+
+// validateQuestionTemplates checks the question text and its translations
+func validateQuestionTemplates(q *Question) error {
+	if err := validateTemplate(q.Question); err != nil {
+		return err
+	}
+	for locale, t := range q.Translations {
+		if err := validateTemplate(t.Question); err != nil {
+			return errors.New("translation " + locale + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// templateContext collects the variables of a request: the first name of the
+// user, who may be nil, the name of the room given as ?room_id=, and the
+// session details given as ?session_type= and ?session_duration= (in minutes)
+func templateContext(c *gin.Context, user *User) (map[string]string, error) {
+	context := make(map[string]string)
+	if user != nil {
+		if fields := strings.Fields(user.Name); len(fields) > 0 {
+			context["first_name"] = fields[0]
+		}
+	}
+
+	if roomID := c.Query("room_id"); roomID != "" {
+		room, err := db.GetRoom(roomID)
+		if errors.Is(err, errRoomNotFound) {
+			return nil, requestError("room not found: " + roomID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		context["room"] = room.Name
+	}
+
+	for _, name := range []string{"session_type", "session_duration"} {
+		value := strings.TrimSpace(c.Query(name))
+		if value == "" {
+			continue
+		}
+		if utf8.RuneCountInString(value) > maxTemplateValueLength {
+			return nil, requestError(name + " must be at most " + strconv.Itoa(maxTemplateValueLength) + " characters")
+		}
+		if strings.IndexFunc(value, isControl) >= 0 {
+			return nil, requestError("invalid " + name)
+		}
+		if name == "session_duration" {
+			if minutes, err := strconv.Atoi(value); err != nil || minutes <= 0 {
+				return nil, requestError("session_duration must be a positive number of minutes")
+			}
+		}
+		context[name] = value
+	}
+	return context, nil
+}
+
+func isControl(r rune) bool {
+	return r < ' ' || r == 0x7f
+}
+
+// renderTemplate fills in the variables of a text and unescapes braces. A
+// variable without a value falls back to its default, or to nothing.
+func renderTemplate(text string, context map[string]string) string {
+	return templatePattern.ReplaceAllStringFunc(text, func(match string) string {
+		if isBraceEscape(match) {
+			return match[:1]
+		}
+		name, def, _ := strings.Cut(match[1:len(match)-1], "|")
+		if value := context[strings.TrimSpace(name)]; value != "" {
+			return value
+		}
+		return def
+	})
+}
+
+func renderQuestions(questions []Question, context map[string]string) []Question {
+	rendered := make([]Question, len(questions))
+	for i, q := range questions {
+		q.Question = renderTemplate(q.Question, context)
+		rendered[i] = q
+	}
+	return rendered
+}
@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func validateThrottle(rule *ThrottleRule) error {
+	if rule == nil {
+		return nil
+	}
+	if rule.MaxPerWeek < 0 {
+		return errors.New("max_per_week must not be negative")
+	}
+	if rule.CooldownHours < 0 {
+		return errors.New("cooldown_hours must not be negative")
+	}
+	if rule.Probability != nil && (*rule.Probability < 0 || *rule.Probability > 1) {
+		return errors.New("probability must be between 0 and 1")
+	}
+	return nil
+}
+
+// decidePrompt applies a throttle rule to a user's earlier decisions. Only
+// prompts that were presented count towards the weekly limit and cooldown.
+// Sampling is drawn last, so users who may not be prompted anyway do not use
+// up a draw.
+func decidePrompt(rule *ThrottleRule, history []PromptDecision, now time.Time, draw float64) PromptDecision {
+	decision := PromptDecision{Present: true, Reason: "allowed", DecidedAt: now}
+	if rule == nil {
+		return decision
+	}
+
+	weekAgo := now.AddDate(0, 0, -7)
+	presented := 0
+	var last time.Time
+	for _, d := range history {
+		if !d.Present {
+			continue
+		}
+		if d.DecidedAt.After(weekAgo) {
+			presented++
+		}
+		if d.DecidedAt.After(last) {
+			last = d.DecidedAt
+		}
+	}
+
+	switch {
+	case rule.MaxPerWeek > 0 && presented >= rule.MaxPerWeek:
+		decision.Present, decision.Reason = false, "weekly_limit"
+	case rule.CooldownHours > 0 && !last.IsZero() && now.Sub(last) < time.Duration(rule.CooldownHours)*time.Hour:
+		decision.Present, decision.Reason = false, "cooldown"
+	case rule.Probability != nil && draw >= *rule.Probability:
+		decision.Present, decision.Reason = false, "sampled_out"
+	}
+	return decision
+}
+
+func handleSetQuestionnaireThrottle(c *gin.Context) {
+	var req ThrottleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	if err := validateThrottle(req.Throttle); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	questionnaire, err := db.SetQuestionnaireThrottle(c.Param("id"), req.Throttle)
+	if err != nil {
+		if errors.Is(err, errQuestionnaireNotFound) {
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to update throttle"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: questionnaire})
+}
+
+// handlePromptQuestionnaire decides whether the client should present a
+// questionnaire to the user now. Every decision is logged, so clients should
+// ask once each time they would show the questionnaire. Anonymous
+// questionnaires only keep the day of each decision, like their submissions,
+// so their cooldown is counted in whole days.
+func handlePromptQuestionnaire(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	questionnaire, err := db.GetQuestionnaire(c.Param("id"))
+	if err != nil || questionnaire.PublishedVersion == 0 {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Questionnaire not found"})
+		return
+	}
+	published, err := db.GetQuestionnaireVersion(questionnaire.ID, questionnaire.PublishedVersion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch questionnaire"})
+		return
+	}
+
+	anonymous := questionnaire.Anonymous || published.Anonymous
+	now := time.Now()
+	if anonymous {
+		now = now.UTC().Truncate(24 * time.Hour)
+	}
+	decision, err := db.DecidePrompt(questionnaire.ID, userID.(string), anonymous, func(history []PromptDecision) PromptDecision {
+		return decidePrompt(questionnaire.Throttle, history, now, rand.Float64())
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to decide prompt"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: decision})
+}
+
+// handleGetPromptDecisions returns the decision log of a questionnaire for
+// analysis, optionally limited by ?from= and ?to=
+func handleGetPromptDecisions(c *gin.Context) {
+	var from, to time.Time
+	var err error
+	if v := c.Query("from"); v != "" {
+		if from, err = parseDateFilter(v, false); err != nil {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if to, err = parseDateFilter(v, true); err != nil {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+			return
+		}
+	}
+
+	decisions, err := db.GetPromptDecisions(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch decisions"})
+		return
+	}
+
+	filtered := []PromptDecision{}
+	for _, d := range decisions {
+		if (!from.IsZero() && d.DecidedAt.Before(from)) || (!to.IsZero() && !d.DecidedAt.Before(to)) {
+			continue
+		}
+		filtered = append(filtered, d)
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: PromptDecisionsResponse{
+			Decisions: filtered,
+		},
+	})
+}
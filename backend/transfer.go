@@ -0,0 +1,291 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// mapCondition copies a condition with its question references replaced
+// through ids. References missing from ids are kept as they are.
+func mapCondition(cond *Condition, ids map[string]string) *Condition {
+	if cond == nil {
+		return nil
+	}
+	mapped := *cond
+	if id, ok := ids[cond.QuestionID]; ok {
+		mapped.QuestionID = id
+	}
+	mapped.All = nil
+	for i := range cond.All {
+		mapped.All = append(mapped.All, *mapCondition(&cond.All[i], ids))
+	}
+	mapped.Any = nil
+	for i := range cond.Any {
+		mapped.Any = append(mapped.Any, *mapCondition(&cond.Any[i], ids))
+	}
+	return &mapped
+}
+
+// mapScoring copies scoring rules with their question references replaced
+// through ids
+func mapScoring(rules []ScoringRule, ids map[string]string) []ScoringRule {
+	var mapped []ScoringRule
+	for _, rule := range rules {
+		items := make([]ScoringItem, len(rule.Items))
+		for i, item := range rule.Items {
+			if id, ok := ids[item.QuestionID]; ok {
+				item.QuestionID = id
+			}
+			items[i] = item
+		}
+		rule.Items = items
+		mapped = append(mapped, rule)
+	}
+	return mapped
+}
+
+// exportQuestionnaire builds the portable document of a questionnaire.
+// Records without a stable key are exported under their ID, which an import
+// into the same database matches again.
+func exportQuestionnaire(questionnaire *Questionnaire, questions []Question) *QuestionnaireDocument {
+	keys := make(map[string]string)
+	for _, q := range questions {
+		keys[q.ID] = q.Key
+		if q.Key == "" {
+			keys[q.ID] = q.ID
+		}
+	}
+
+	doc := &QuestionnaireDocument{
+		Key:          questionnaire.Key,
+		Title:        questionnaire.Title,
+		Description:  questionnaire.Description,
+		Purpose:      questionnaire.Purpose,
+		Anonymous:    questionnaire.Anonymous,
+		Translations: questionnaire.Translations,
+		Scoring:      mapScoring(questionnaire.Scoring, keys),
+		ShowScores:   questionnaire.ShowScores,
+		Questions:    []Question{},
+	}
+	if doc.Key == "" {
+		doc.Key = questionnaire.ID
+	}
+
+	for _, q := range questions {
+		q.Key = keys[q.ID]
+		q.ID = ""
+		q.Position = 0
+		q.Condition = mapCondition(q.Condition, keys)
+		doc.Questions = append(doc.Questions, q)
+	}
+	return doc
+}
+
+// documentQuestion is a question as written to a document. The nil fields
+// take the place of the question's database ID and position, which documents
+// leave out.
+type documentQuestion struct {
+	Question
+	ID       *string `json:"id,omitempty"`
+	Position *int    `json:"position,omitempty"`
+}
+
+// MarshalJSON writes the questions of a document without their database IDs
+// and positions
+func (doc QuestionnaireDocument) MarshalJSON() ([]byte, error) {
+	type document QuestionnaireDocument
+	questions := make([]documentQuestion, len(doc.Questions))
+	for i, q := range doc.Questions {
+		questions[i] = documentQuestion{Question: q}
+	}
+	return json.Marshal(struct {
+		document
+		Questions []documentQuestion `json:"questions"`
+	}{document(doc), questions})
+}
+
+// validateDocument checks a document on its own before it is imported.
+// Conditions and scoring are checked with the keys standing in for IDs.
+func validateDocument(doc *QuestionnaireDocument) error {
+	if doc.Key == "" {
+		return errors.New("questionnaire key is required")
+	}
+
+	questionnaire := Questionnaire{
+		Key:          doc.Key,
+		Title:        doc.Title,
+		Purpose:      doc.Purpose,
+		Translations: doc.Translations,
+	}
+	if err := validateQuestionnaire(&questionnaire); err != nil {
+		return err
+	}
+	doc.Title = questionnaire.Title
+
+	if len(doc.Questions) == 0 {
+		return errors.New("a questionnaire needs at least one question")
+	}
+	seen := make(map[string]bool)
+	for i := range doc.Questions {
+		q := &doc.Questions[i]
+		if q.Key == "" {
+			return fmt.Errorf("question %d: key is required", i+1)
+		}
+		if seen[q.Key] {
+			return errors.New("duplicate question key: " + q.Key)
+		}
+		seen[q.Key] = true
+
+		q.ID = q.Key
+		if err := validateQuestion(q); err != nil {
+			return errors.New("question " + q.Key + ": " + err.Error())
+		}
+		q.Position = 0
+		q.Archived, q.ArchivedBy, q.ArchivedAt = false, "", nil
+	}
+
+	err := checkConditions(doc.Questions)
+	if err == nil {
+		err = checkScoring(doc.Scoring, doc.Questions)
+	}
+	for i := range doc.Questions {
+		doc.Questions[i].ID = ""
+	}
+	return err
+}
+
+// parseDocument reads a document in YAML or JSON, which is valid YAML.
+// Going through JSON keeps the field names and value handling the same for
+// both formats.
+func parseDocument(data []byte) (*QuestionnaireDocument, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc QuestionnaireDocument
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// marshalDocument encodes a document as indented JSON or as YAML. YAML is
+// produced from the JSON encoding so both formats use the same field names.
+func marshalDocument(doc *QuestionnaireDocument, format string) ([]byte, error) {
+	buf, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil || format == "json" {
+		return buf, err
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(buf, &node); err != nil {
+		return nil, err
+	}
+	clearYAMLStyle(&node)
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), enc.Close()
+}
+
+// clearYAMLStyle drops the flow and quoting style the JSON input gave the
+// nodes, so the output reads like hand written YAML
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
+
+// findQuestionnaire looks up a questionnaire by ID or stable key
+func findQuestionnaire(ref string) (*Questionnaire, error) {
+	questionnaire, err := db.GetQuestionnaire(ref)
+	if errors.Is(err, errQuestionnaireNotFound) {
+		return db.GetQuestionnaireByKey(ref)
+	}
+	return questionnaire, err
+}
+
+func handleExportQuestionnaire(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "yaml" {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "format must be json or yaml"})
+		return
+	}
+
+	questionnaire, err := findQuestionnaire(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Questionnaire not found"})
+		return
+	}
+
+	questions, err := db.GetQuestionnaireQuestions(questionnaire)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch questions"})
+		return
+	}
+
+	doc := exportQuestionnaire(questionnaire, questions)
+	buf, err := marshalDocument(doc, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to export questionnaire"})
+		return
+	}
+
+	contentType := "application/json"
+	if format == "yaml" {
+		contentType = "application/yaml"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", doc.Key+"."+format))
+	c.Data(http.StatusOK, contentType, buf)
+}
+
+// handleImportQuestionnaire creates or updates a questionnaire and its
+// questions from a JSON or YAML document, matching them by key. Importing
+// the same document again changes nothing.
+func handleImportQuestionnaire(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	doc, err := parseDocument(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Invalid document: " + err.Error()})
+		return
+	}
+	if err := validateDocument(doc); err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		return
+	}
+
+	result, err := db.ImportQuestionnaire(doc, false)
+	if err != nil {
+		if isRequestError(err) {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to import questionnaire"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: result})
+}
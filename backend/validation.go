@@ -0,0 +1,405 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultTextMaxLength = 500
+	maxTextMaxLength     = 10000
+)
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// validateKey checks an optional stable key used to match questions and
+// questionnaires on import
+func validateKey(key string) error {
+	if key != "" && !keyPattern.MatchString(key) {
+		return errors.New("invalid key: " + key + " (use letters, digits, '.', '_' and '-')")
+	}
+	return nil
+}
+
+// validateQuestion checks the type specific settings of a question and clears
+// settings that do not apply to its type
+func validateQuestion(q *Question) error {
+	q.Question = strings.TrimSpace(q.Question)
+	if q.Question == "" {
+		return errors.New("question text is required")
+	}
+
+	if err := validateKey(q.Key); err != nil {
+		return err
+	}
+
+	validType := false
+	for _, t := range questionTypes {
+		if q.Type == t {
+			validType = true
+			break
+		}
+	}
+	if !validType {
+		return errors.New("invalid question type")
+	}
+
+	if q.Type != "choice" && q.Type != "multi_choice" {
+		q.Options = nil
+	}
+	if q.Type != "slider" {
+		q.Step = 0
+	}
+	if q.Type != "text" {
+		q.MaxLength = 0
+	}
+	if q.Type != "scale" && q.Type != "slider" {
+		q.Min, q.Max = 0, 0
+		q.MinLabel, q.MaxLabel = "", ""
+	}
+
+	switch q.Type {
+	case "scale":
+		if q.Max <= q.Min {
+			return errors.New("scale questions require min and max values with max greater than min")
+		}
+	case "slider":
+		if q.Max <= q.Min {
+			return errors.New("slider questions require min and max values with max greater than min")
+		}
+		if q.Step <= 0 {
+			return errors.New("slider questions require a positive step")
+		}
+		if (q.Max-q.Min)%q.Step != 0 {
+			return errors.New("slider step must evenly divide the range between min and max")
+		}
+	case "choice", "multi_choice":
+		if len(q.Options) < 2 {
+			return errors.New("choice questions require at least two options")
+		}
+		seen := make(map[string]bool)
+		for i := range q.Options {
+			opt := &q.Options[i]
+			opt.Value = strings.TrimSpace(opt.Value)
+			if opt.Value == "" {
+				return errors.New("option values must not be empty")
+			}
+			if seen[opt.Value] {
+				return errors.New("duplicate option value: " + opt.Value)
+			}
+			seen[opt.Value] = true
+			if opt.Label == "" {
+				opt.Label = opt.Value
+			}
+		}
+	case "text":
+		if q.MaxLength == 0 {
+			q.MaxLength = defaultTextMaxLength
+		}
+		if q.MaxLength < 0 || q.MaxLength > maxTextMaxLength {
+			return errors.New("text max_length must be between 1 and 10000")
+		}
+	}
+
+	if err := validateQuestionTranslations(q); err != nil {
+		return err
+	}
+	return validateQuestionTemplates(q)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parsedAnswer is an answer decoded into its typed value
+type parsedAnswer struct {
+	field string
+	value interface{}
+}
+
+// parseAnswers decodes every answer against the question it refers to.
+// Unanswered questions are left out; invalid lists questions whose answer
+// could not be decoded.
+func parseAnswers(questionMap map[string]Question, answers []Answer) (map[string]parsedAnswer, map[string]bool, []ValidationError) {
+	var errs []ValidationError
+	parsed := make(map[string]parsedAnswer)
+	invalid := make(map[string]bool)
+	for i, answer := range answers {
+		field := fmt.Sprintf("answers[%d]", i)
+
+		q, exists := questionMap[answer.ID]
+		if !exists {
+			errs = append(errs, ValidationError{Field: field + ".id", QuestionID: answer.ID, Message: "unknown question"})
+			continue
+		}
+		if _, dup := parsed[answer.ID]; dup || invalid[answer.ID] {
+			errs = append(errs, ValidationError{Field: field + ".id", QuestionID: answer.ID, Message: "question answered more than once"})
+			continue
+		}
+
+		value, err := parseAnswerValue(q, answer)
+		if err != nil {
+			invalid[answer.ID] = true
+			errs = append(errs, ValidationError{Field: field + ".value", QuestionID: answer.ID, Message: err.Error()})
+			continue
+		}
+		if value != nil {
+			parsed[answer.ID] = parsedAnswer{field: field, value: value}
+		}
+	}
+	return parsed, invalid, errs
+}
+
+func normalizedAnswer(questionID string, value interface{}) (Answer, error) {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return Answer{}, err
+	}
+	return Answer{
+		ID:       questionID,
+		Question: answerString(value),
+		Value:    buf,
+	}, nil
+}
+
+// validateAnswers checks answers against the questions they refer to and
+// evaluates display conditions in question order: hidden questions are not
+// required and must not be answered. It returns the answers, normalized to
+// their question types and in question order, the hidden questions, and
+// every problem it found.
+func validateAnswers(questions []Question, answers []Answer) ([]Answer, []string, []ValidationError) {
+	questionMap := make(map[string]Question)
+	for _, q := range questions {
+		questionMap[q.ID] = q
+	}
+
+	parsed, invalid, errs := parseAnswers(questionMap, answers)
+
+	values := make(map[string]interface{})
+	normalized := make([]Answer, 0, len(parsed))
+	hidden := []string{}
+	for _, q := range questions {
+		answer, answered := parsed[q.ID]
+
+		if q.Condition != nil && !evaluateCondition(q.Condition, questionMap, values) {
+			hidden = append(hidden, q.ID)
+			if answered {
+				errs = append(errs, ValidationError{Field: answer.field + ".id", QuestionID: q.ID, Message: "question is not shown for the given answers"})
+			}
+			continue
+		}
+
+		if !answered {
+			if q.Required && !invalid[q.ID] {
+				errs = append(errs, ValidationError{Field: "answers", QuestionID: q.ID, Message: "answer is required"})
+			}
+			continue
+		}
+
+		a, err := normalizedAnswer(q.ID, answer.value)
+		if err != nil {
+			errs = append(errs, ValidationError{Field: answer.field + ".value", QuestionID: q.ID, Message: err.Error()})
+			continue
+		}
+
+		values[q.ID] = answer.value
+		normalized = append(normalized, a)
+	}
+
+	return normalized, hidden, errs
+}
+
+// validateDraftAnswers checks the answers given so far without enforcing
+// required questions or display conditions, which may still change before
+// the questionnaire is submitted
+func validateDraftAnswers(questions []Question, answers []Answer) ([]Answer, []ValidationError) {
+	questionMap := make(map[string]Question)
+	for _, q := range questions {
+		questionMap[q.ID] = q
+	}
+
+	parsed, _, errs := parseAnswers(questionMap, answers)
+
+	normalized := make([]Answer, 0, len(parsed))
+	for _, q := range questions {
+		answer, answered := parsed[q.ID]
+		if !answered {
+			continue
+		}
+		a, err := normalizedAnswer(q.ID, answer.value)
+		if err != nil {
+			errs = append(errs, ValidationError{Field: answer.field + ".value", QuestionID: q.ID, Message: err.Error()})
+			continue
+		}
+		normalized = append(normalized, a)
+	}
+
+	return normalized, errs
+}
+
+// parseAnswerValue decodes the answer to q into an int, bool, string or
+// []string depending on the question type. A nil value means the question
+// was left unanswered.
+func parseAnswerValue(q Question, answer Answer) (interface{}, error) {
+	raw := answer.Value
+	if len(raw) == 0 || string(raw) == "null" {
+		if answer.Question == "" {
+			return nil, nil
+		}
+		return parseLegacyAnswer(q, answer.Question)
+	}
+
+	switch q.Type {
+	case "scale", "slider":
+		var f float64
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return nil, errors.New("value must be a number")
+		}
+		if f != math.Trunc(f) {
+			return nil, errors.New("value must be a whole number")
+		}
+		return checkNumber(q, int(f))
+	case "choice":
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, errors.New("value must be a string")
+		}
+		return checkChoice(q, v)
+	case "multi_choice":
+		var v []string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, errors.New("value must be an array of strings")
+		}
+		return checkMultiChoice(q, v)
+	case "text":
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, errors.New("value must be a string")
+		}
+		return checkText(q, v)
+	case "boolean":
+		var v bool
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, errors.New("value must be true or false")
+		}
+		return v, nil
+	}
+	return nil, errors.New("unsupported question type")
+}
+
+// parseLegacyAnswer interprets an answer sent in the string "question" field
+func parseLegacyAnswer(q Question, s string) (interface{}, error) {
+	switch q.Type {
+	case "scale", "slider":
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return nil, errors.New("value must be a whole number")
+		}
+		return checkNumber(q, n)
+	case "choice":
+		return checkChoice(q, strings.TrimSpace(s))
+	case "multi_choice":
+		var values []string
+		for _, v := range strings.Split(s, ",") {
+			values = append(values, strings.TrimSpace(v))
+		}
+		return checkMultiChoice(q, values)
+	case "text":
+		return checkText(q, s)
+	case "boolean":
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return nil, errors.New("value must be true or false")
+		}
+		return b, nil
+	}
+	return nil, errors.New("unsupported question type")
+}
+
+func checkNumber(q Question, n int) (interface{}, error) {
+	if n < q.Min || n > q.Max {
+		return nil, fmt.Errorf("value must be between %d and %d", q.Min, q.Max)
+	}
+	if q.Type == "slider" && (n-q.Min)%q.Step != 0 {
+		return nil, fmt.Errorf("value must be a multiple of %d from %d", q.Step, q.Min)
+	}
+	return n, nil
+}
+
+func hasOption(q Question, value string) bool {
+	for _, opt := range q.Options {
+		if opt.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func checkChoice(q Question, v string) (interface{}, error) {
+	if v == "" {
+		return nil, nil
+	}
+	if !hasOption(q, v) {
+		return nil, errors.New("value is not one of the options: " + v)
+	}
+	return v, nil
+}
+
+func checkMultiChoice(q Question, values []string) (interface{}, error) {
+	seen := make(map[string]bool)
+	var result []string
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if !hasOption(q, v) {
+			return nil, errors.New("value is not one of the options: " + v)
+		}
+		if seen[v] {
+			return nil, errors.New("option selected more than once: " + v)
+		}
+		seen[v] = true
+		result = append(result, v)
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+func checkText(q Question, v string) (interface{}, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	if utf8.RuneCountInString(v) > q.MaxLength {
+		return nil, fmt.Errorf("value must be at most %d characters", q.MaxLength)
+	}
+	return v, nil
+}
+
+// answerString renders a normalized answer value for the legacy string field
+func answerString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case []string:
+		return strings.Join(v, ",")
+	}
+	return ""
+}
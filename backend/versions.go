@@ -0,0 +1,191 @@
+package backend
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (d *QuestionnaireDiff) empty() bool {
+	return len(d.Changes) == 0 && len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 && !d.Reordered
+}
+
+// fieldMap flattens a value into its top level JSON fields so versions can be
+// compared field by field without listing every field here
+func fieldMap(v interface{}) map[string]interface{} {
+	m := make(map[string]interface{})
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return m
+	}
+	json.Unmarshal(buf, &m)
+	return m
+}
+
+func diffFields(before, after map[string]interface{}, skip ...string) []FieldChange {
+	skipped := make(map[string]bool)
+	for _, f := range skip {
+		skipped[f] = true
+	}
+
+	var keys []string
+	for k := range before {
+		keys = append(keys, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []FieldChange{}
+	for _, k := range keys {
+		if skipped[k] || reflect.DeepEqual(before[k], after[k]) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: k, Before: before[k], After: after[k]})
+	}
+	return changes
+}
+
+func diffVersions(from, to *QuestionnaireVersion) *QuestionnaireDiff {
+	d := &QuestionnaireDiff{
+		QuestionnaireID: to.QuestionnaireID,
+		From:            from.Version,
+		To:              to.Version,
+		Added:           []Question{},
+		Removed:         []Question{},
+		Changed:         []QuestionChange{},
+	}
+
+	d.Changes = diffFields(fieldMap(from), fieldMap(to),
+		"questionnaire_id", "version", "questions", "published_at", "published_by")
+
+	fromQuestions := make(map[string]Question)
+	for _, q := range from.Questions {
+		fromQuestions[q.ID] = q
+	}
+	toQuestions := make(map[string]Question)
+	for _, q := range to.Questions {
+		toQuestions[q.ID] = q
+	}
+
+	var fromOrder, toOrder []string
+	for _, q := range from.Questions {
+		if _, ok := toQuestions[q.ID]; !ok {
+			d.Removed = append(d.Removed, q)
+			continue
+		}
+		fromOrder = append(fromOrder, q.ID)
+	}
+	for _, q := range to.Questions {
+		before, ok := fromQuestions[q.ID]
+		if !ok {
+			d.Added = append(d.Added, q)
+			continue
+		}
+		toOrder = append(toOrder, q.ID)
+		if changes := diffFields(fieldMap(before), fieldMap(q), "position"); len(changes) > 0 {
+			d.Changed = append(d.Changed, QuestionChange{QuestionID: q.ID, Changes: changes})
+		}
+	}
+	d.Reordered = !reflect.DeepEqual(fromOrder, toOrder)
+
+	return d
+}
+
+func handlePublishQuestionnaire(c *gin.Context) {
+	userID, _ := c.Get("userID")
+
+	version, err := db.PublishQuestionnaire(c.Param("id"), userID.(string))
+	if err != nil {
+		switch {
+		case errors.Is(err, errQuestionnaireNotFound):
+			c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: err.Error()})
+		case isRequestError(err):
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to publish questionnaire"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: version})
+}
+
+func handleGetQuestionnaireVersions(c *gin.Context) {
+	versions, err := db.GetQuestionnaireVersions(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, GenericResponse{Success: false, Data: "Failed to fetch versions"})
+		return
+	}
+
+	if versions == nil {
+		versions = []QuestionnaireVersion{}
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{
+		Success: true,
+		Data: QuestionnaireVersionsResponse{
+			Versions: versions,
+		},
+	})
+}
+
+func handleGetQuestionnaireVersion(c *gin.Context) {
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "Invalid version"})
+		return
+	}
+
+	version, err := db.GetQuestionnaireVersion(c.Param("id"), number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Version not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: version})
+}
+
+func handleDiffQuestionnaireVersions(c *gin.Context) {
+	id := c.Param("id")
+
+	questionnaire, err := db.GetQuestionnaire(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Questionnaire not found"})
+		return
+	}
+
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "from query parameter must be a version number"})
+		return
+	}
+	to := questionnaire.PublishedVersion
+	if c.Query("to") != "" {
+		if to, err = strconv.Atoi(c.Query("to")); err != nil {
+			c.JSON(http.StatusBadRequest, GenericResponse{Success: false, Data: "to query parameter must be a version number"})
+			return
+		}
+	}
+
+	fromVersion, err := db.GetQuestionnaireVersion(id, from)
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Version " + strconv.Itoa(from) + " not found"})
+		return
+	}
+	toVersion, err := db.GetQuestionnaireVersion(id, to)
+	if err != nil {
+		c.JSON(http.StatusNotFound, GenericResponse{Success: false, Data: "Version " + strconv.Itoa(to) + " not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, GenericResponse{Success: true, Data: diffVersions(fromVersion, toVersion)})
+}
@@ -2,10 +2,21 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	"tiramisu/backend"
 )
 
 func main() {
 	flag.Parse()
+
+	if flag.NArg() > 0 {
+		if err := backend.Command(flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	backend.Main()
 }